@@ -0,0 +1,139 @@
+package blob
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"errors"
+	"io"
+	"path"
+	"strconv"
+)
+
+// archiveID converts a path inside an archive to an ID, the same way the blob
+// command names files in a folder: relative to the root and separated by
+// slashes. It returns false for paths that do not name anything below the
+// root.
+func archiveID(name string) (string, bool) {
+	id := path.Clean("/" + name)[1:]
+	return id, id != ""
+}
+
+// FromTar reads a tar archive and returns a blob with one item per regular
+// file, in archive order. The IDs are the paths inside the archive, cleaned of
+// leading slashes and dot elements. Directories, links and other special
+// entries are skipped.
+func FromTar(r io.Reader) (*Blob, error) {
+	b := New()
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return b, nil
+		}
+		if err != nil {
+			return nil, errors.New("read tar: " + err.Error())
+		}
+		id, ok := archiveID(hdr.Name)
+		if hdr.Typeflag != tar.TypeReg || !ok {
+			continue
+		}
+		if hdr.Size < 0 {
+			return nil, errors.New("read tar file " + strconv.Quote(hdr.Name) + ": negative size")
+		}
+		data, err := readBytes(tr, uint64(hdr.Size))
+		if err != nil {
+			return nil, errors.New("read tar file " + strconv.Quote(hdr.Name) + ": " + err.Error())
+		}
+		b.Append(id, data)
+	}
+}
+
+// FromZip returns a blob with one item per regular file in the given zip
+// archive, in archive order. The IDs are the paths inside the archive, cleaned
+// of leading slashes and dot elements. Directories and other special entries
+// are skipped.
+func FromZip(z *zip.Reader) (*Blob, error) {
+	b := New()
+	for _, f := range z.File {
+		id, ok := archiveID(f.Name)
+		if !f.Mode().IsRegular() || !ok {
+			continue
+		}
+		data, err := readZipFile(f)
+		if err != nil {
+			return nil, errors.New("read zip file " + strconv.Quote(f.Name) + ": " + err.Error())
+		}
+		b.Append(id, data)
+	}
+	return b, nil
+}
+
+func readZipFile(f *zip.File) ([]byte, error) {
+	r, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	data, err := readBytes(r, f.UncompressedSize64)
+	if err != nil {
+		return nil, err
+	}
+	// The zip reader only checks the CRC-32 once it reaches the end of the
+	// file, so read on until it reports io.EOF.
+	extra, err := io.Copy(io.Discard, io.LimitReader(r, 1))
+	if err != nil {
+		return nil, err
+	}
+	if extra != 0 {
+		return nil, errors.New("file is larger than its declared size")
+	}
+	return data, nil
+}
+
+// WriteTar writes the blob as a tar archive with one regular file per item.
+// The IDs are used as the file paths, so IDs with slashes end up in
+// sub-directories when the archive is extracted.
+func (b *Blob) WriteTar(w io.Writer) error {
+	tw := tar.NewWriter(w)
+	for i := range b.items {
+		err := tw.WriteHeader(&tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     b.items[i].id,
+			Mode:     0644,
+			Size:     int64(b.items[i].end - b.items[i].start),
+		})
+		if err != nil {
+			return errors.New("blob.Blob.WriteTar: " + err.Error())
+		}
+		if err := b.writeItem(tw, &b.items[i]); err != nil {
+			return errors.New("blob.Blob.WriteTar: " + err.Error())
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return errors.New("blob.Blob.WriteTar: " + err.Error())
+	}
+	return nil
+}
+
+// WriteZip writes the blob as a zip archive with one compressed file per item.
+// The IDs are used as the file paths, so IDs with slashes end up in
+// sub-directories when the archive is extracted.
+func (b *Blob) WriteZip(w io.Writer) error {
+	zw := zip.NewWriter(w)
+	for i := range b.items {
+		f, err := zw.CreateHeader(&zip.FileHeader{
+			Name:   b.items[i].id,
+			Method: zip.Deflate,
+		})
+		if err != nil {
+			return errors.New("blob.Blob.WriteZip: " + err.Error())
+		}
+		if err := b.writeItem(f, &b.items[i]); err != nil {
+			return errors.New("blob.Blob.WriteZip: " + err.Error())
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return errors.New("blob.Blob.WriteZip: " + err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+package blob_test
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"testing"
+
+	"github.com/gonutz/blob"
+)
+
+func TestTarRoundTrip(t *testing.T) {
+	original := blob.New()
+	original.Append("index.html", []byte("<html></html>"))
+	original.Append("static/logo.png", []byte{1, 2, 3})
+	original.Append("static/empty", nil)
+	var buf bytes.Buffer
+	if err := original.WriteTar(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := blob.FromTar(&buf)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkBlobContent(t, b,
+		"index.html", "<html></html>",
+		"static/logo.png", "\x01\x02\x03",
+		"static/empty", "",
+	)
+}
+
+func TestZipRoundTrip(t *testing.T) {
+	original := blob.New()
+	original.Append("index.html", []byte("<html></html>"))
+	original.Append("static/logo.png", []byte{1, 2, 3})
+	original.Append("static/empty", nil)
+	var buf bytes.Buffer
+	if err := original.WriteZip(&buf); err != nil {
+		t.Fatal(err)
+	}
+	z, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := blob.FromZip(z)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkBlobContent(t, b,
+		"index.html", "<html></html>",
+		"static/logo.png", "\x01\x02\x03",
+		"static/empty", "",
+	)
+}
+
+func TestFromTarSkipsSpecialEntriesAndCleansPaths(t *testing.T) {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	tw.WriteHeader(&tar.Header{Typeflag: tar.TypeDir, Name: "./assets/", Mode: 0755})
+	tw.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: "./assets/a.txt", Mode: 0644, Size: 1})
+	tw.Write([]byte("a"))
+	tw.WriteHeader(&tar.Header{Typeflag: tar.TypeSymlink, Name: "assets/link", Linkname: "a.txt"})
+	tw.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: "/abs/b.txt", Mode: 0644, Size: 1})
+	tw.Write([]byte("b"))
+	tw.Close()
+
+	b, err := blob.FromTar(&buf)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkBlobContent(t, b,
+		"assets/a.txt", "a",
+		"abs/b.txt", "b",
+	)
+}
+
+func TestFromZipSkipsDirectories(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	zw.Create("dir/")
+	f, _ := zw.Create("dir/file")
+	f.Write([]byte("file"))
+	zw.Close()
+	z, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := blob.FromZip(z)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkBlobContent(t, b, "dir/file", "file")
+}
+
+func TestFromTarFailsOnBrokenArchive(t *testing.T) {
+	b := blob.New()
+	b.Append("file", bytes.Repeat([]byte{1}, 100))
+	var buf bytes.Buffer
+	b.WriteTar(&buf)
+
+	_, err := blob.FromTar(bytes.NewReader(buf.Bytes()[:520]))
+
+	if err == nil {
+		t.Error("error expected")
+	}
+}
+
+func TestFromZipChecksCRC(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	f, err := zw.CreateHeader(&zip.FileHeader{Name: "file", Method: zip.Store})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write([]byte("stored data"))
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	corrupt := bytes.Replace(buf.Bytes(), []byte("stored data"), []byte("stored dada"), 1)
+	z, err := zip.NewReader(bytes.NewReader(corrupt), int64(len(corrupt)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = blob.FromZip(z)
+
+	if err == nil {
+		t.Error("error expected for corrupted data")
+	}
+
+	z, err = zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	z.File[0].CRC32 ^= 1
+
+	_, err = blob.FromZip(z)
+
+	if err == nil {
+		t.Error("error expected for corrupted CRC")
+	}
+}
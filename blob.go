@@ -1,40 +1,83 @@
 package blob
 
 import (
+	"bufio"
 	"bytes"
+	"context"
 	"encoding/binary"
 	"errors"
+	"fmt"
 	"io"
+	"math"
+	"sort"
+	"sync"
+	"sync/atomic"
 )
 
 // Blob is an in-memory data buffer, matching string IDs to byte slices (blobs).
 type Blob struct {
 	header
 	data []byte
+	// lazy holds the items added with AppendReader or AppendFile, in order.
+	// Their data is not part of data, see dataOffset.
+	lazy []*lazyItem
+	// lazyMu serializes reading the data of lazy items, their readers may be
+	// shared. It is created with the first lazy item.
+	lazyMu *sync.Mutex
 }
 
-type header []indexItem
+type header struct {
+	items []indexItem
+	// sorted is true if the items are sorted by ID, in which case they can be
+	// looked up with a binary search.
+	sorted bool
+	// length is the size of the encoded header in bytes, without the header
+	// length itself.
+	length uint64
+	// indexOffset is the position of the header of a blob with a trailing
+	// index, see Edit, and 0 for all other blobs. If it is set, the items'
+	// start and end are counted from the start of the blob.
+	indexOffset uint64
+}
 
 type indexItem struct {
 	id    string
 	start uint64
 	end   uint64
+	// lazy is set for items of a Blob whose data is read from elsewhere.
+	lazy *lazyItem
 }
 
 // ItemCount returns the number of blob items, i.e. pairs of string IDs and byte
 // slices. When using GetIDAtIndex or GetByIndex, valid inidices range from 0 to
 // ItemCount()-1.
-func (h header) ItemCount() int {
-	return len(h)
+func (h *header) ItemCount() int {
+	return len(h.items)
 }
 
 // GetIDAtIndex returns the ID of the entry at index i or the empty string if
 // the given index is out of bounds. Call ItemCount for the number of items.
-func (h header) GetIDAtIndex(i int) string {
-	if i < 0 || i >= len(h) {
+func (h *header) GetIDAtIndex(i int) string {
+	if i < 0 || i >= len(h.items) {
 		return ""
 	}
-	return h[i].id
+	return h.items[i].id
+}
+
+// find returns the index of the first entry with the given ID.
+func (h *header) find(id string) (int, bool) {
+	if h.sorted {
+		i := sort.Search(len(h.items), func(i int) bool {
+			return h.items[i].id >= id
+		})
+		return i, i < len(h.items) && h.items[i].id == id
+	}
+	for i := range h.items {
+		if h.items[i].id == id {
+			return i, true
+		}
+	}
+	return -1, false
 }
 
 // New creates an empty blob. You can add data to it using Append. After adding
@@ -45,12 +88,16 @@ func New() *Blob {
 
 // Append adds the given data at the end of the blob.
 func (b *Blob) Append(id string, data []byte) {
-	b.header = append(
-		b.header,
+	b.sorted = len(b.items) == 0 ||
+		b.sorted && b.items[len(b.items)-1].id <= id
+	b.length += 2 + uint64(len(id)) + 8
+	start := b.dataSize()
+	b.items = append(
+		b.items,
 		indexItem{
-			id,
-			uint64(len(b.data)),
-			uint64(len(b.data) + len(data)),
+			id:    id,
+			start: start,
+			end:   start + uint64(len(data)),
 		},
 	)
 	b.data = append(b.data, data...)
@@ -58,28 +105,32 @@ func (b *Blob) Append(id string, data []byte) {
 
 // GetByID searches the blob for an entry with the given ID and returns the
 // first one found. If there is no entry with the given ID, data will be nil and
-// found will be false.
+// found will be false. The data of entries added with AppendReader or
+// AppendFile is read first. If that fails, found is false as well, use ReadAll
+// to get the error.
 func (b *Blob) GetByID(id string) (data []byte, found bool) {
-	for i := range b.header {
-		if b.header[i].id == id {
-			data = b.data[b.header[i].start:b.header[i].end]
-			found = true
-			return
-		}
+	if i, ok := b.find(id); ok {
+		return b.item(i)
 	}
 	return
 }
 
 // GetByIndex returns the data of the entry at index i. If the index is out of
 // bounds, data will be nil and found will be false. Call ItemCount for the
-// number of items.
+// number of items. Like for GetByID, found is also false if reading the data
+// of an entry added with AppendReader or AppendFile fails.
 func (b *Blob) GetByIndex(i int) (data []byte, found bool) {
-	if i < 0 || i >= len(b.header) {
+	if i < 0 || i >= len(b.items) {
 		return
 	}
-	data = b.data[b.header[i].start:b.header[i].end]
-	found = true
-	return
+	return b.item(i)
+}
+
+// item returns the data of the entry at the valid index i. The data of lazy
+// items is read, ok is false if that fails.
+func (b *Blob) item(i int) (data []byte, ok bool) {
+	data, err := b.load(&b.items[i])
+	return data, err == nil
 }
 
 var byteOrder = binary.LittleEndian
@@ -88,6 +139,37 @@ var byteOrder = binary.LittleEndian
 // Write it. If any of the IDs is longer than MaxIDLen, Write will fail.
 const MaxIDLen = 65535
 
+const (
+	// headerFlagSorted is set in the header length if the header is sorted by
+	// ID.
+	headerFlagSorted = 1 << 31
+	// headerFlagTrailing replaces the header length if the header is stored
+	// at the end of the blob, see Edit. It is followed by the uint64 offset of
+	// the header.
+	headerFlagTrailing = 1 << 30
+	// headerFlagMask covers the two highest bits of the header length, they
+	// are reserved for flags.
+	headerFlagMask = 3 << 30
+	// maxHeaderLength is the largest header that can be written, all bits
+	// below the flags.
+	maxHeaderLength = 1<<30 - 1
+)
+
+// WriteOptions control the output of WriteWithOptions.
+type WriteOptions struct {
+	// SortIDs sorts the header by ID and stores the data in that order. Items
+	// with equal IDs keep their relative order. The header is flagged as
+	// sorted so Read and Open can look up IDs with a binary search. This makes
+	// the output independent of the order in which items were appended.
+	SortIDs bool
+	// Progress is called while writing, if it is not nil.
+	Progress ProgressFunc
+	// Backup keeps the previous version of the file with BackupSuffix
+	// appended to its path. It is only used by WriteFile and
+	// WriteFileContext.
+	Backup bool
+}
+
 // Write writes the whole binary blob to the given writer. The format is as
 // follows, all numbers are encoded in little endian byte order:
 //
@@ -103,60 +185,197 @@ const MaxIDLen = 65535
 // since this can not be represented in the above format (uint16 is used for the
 // ID string's length).
 //
+// The two highest bits of the header length are reserved for flags. The
+// highest bit is set if the header is sorted by ID, see WriteOptions.SortIDs.
+// The header length itself can thus be at most 2^30-1 bytes. If only the
+// second highest bit is set, the header is stored at the end of the blob
+// instead, see Edit.
+//
 // Note that the header does not store offsets into the data explicitly, it only
 // stores the length of each item so the offset can be computed from the
 // cumulative sum of all data lengths of items that come before it.
 func (b *Blob) Write(w io.Writer) (err error) {
-	buffer := bytes.NewBuffer(nil)
-	for i := range b.header {
-		// first write the ID length and then the ID
-		if len(b.header[i].id) > MaxIDLen {
-			return errors.New("blob.Blob.Write: ID is too long")
-		}
-		// writing to bytes.Buffer never returns error != nil so do not check it
-		binary.Write(buffer, byteOrder, uint16(len(b.header[i].id)))
-		buffer.Write([]byte(b.header[i].id))
-		length := b.header[i].end - b.header[i].start
-		binary.Write(buffer, byteOrder, length)
+	return b.WriteWithOptions(w, WriteOptions{})
+}
+
+// WriteWithOptions writes the blob like Write but lets you control the output
+// with the given options.
+func (b *Blob) WriteWithOptions(w io.Writer, opts WriteOptions) (err error) {
+	return b.WriteContext(context.Background(), w, opts)
+}
+
+// WriteContext writes the blob like WriteWithOptions but stops when ctx is
+// canceled. Cancellation is checked between items and while writing large
+// items. The returned error then wraps ctx.Err() and names the item that was
+// being written.
+func (b *Blob) WriteContext(ctx context.Context, w io.Writer, opts WriteOptions) (err error) {
+	items := b.items
+	if opts.SortIDs && !b.sorted {
+		items = sortedItems(items)
+	}
+	headerData, err := encodeHeader(items, opts.SortIDs)
+	if err != nil {
+		return errors.New("blob.Blob.Write: " + err.Error())
+	}
+	if t := newTracker(ctx, opts.Progress, int64(len(headerData)), items); t != nil {
+		w = &trackingWriter{w, t}
 	}
 	// write the header length
-	err = binary.Write(w, byteOrder, uint32(buffer.Len()))
+	_, err = w.Write(headerData[:4])
 	if err != nil {
-		err = errors.New("blob.Blob.Write: cannot write header length: " + err.Error())
+		err = fmt.Errorf("blob.Blob.Write: cannot write header length: %w", err)
 		return
 	}
 	// write the actual header data
-	_, err = w.Write(buffer.Bytes())
+	_, err = w.Write(headerData[4:])
 	if err != nil {
-		err = errors.New("write blob header: " + err.Error())
+		err = fmt.Errorf("write blob header: %w", err)
 		return
 	}
-	// write the data
-	_, err = w.Write(b.data)
+	// write the data, if it was not re-ordered and is all in memory it is
+	// written all at once
+	if len(b.lazy) == 0 && (!opts.SortIDs || b.sorted) {
+		_, err = w.Write(b.data)
+	} else {
+		for i := range items {
+			err = b.writeItem(w, &items[i])
+			if err != nil {
+				break
+			}
+		}
+	}
 	if err != nil {
-		err = errors.New("write blob data: " + err.Error())
+		err = fmt.Errorf("write blob data: %w", err)
 		return
 	}
 	return nil
 }
 
-func readHeader(r io.Reader) (header, uint64, error) {
+// sortedItems returns a copy of items, stable sorted by ID.
+func sortedItems(items []indexItem) []indexItem {
+	sorted := make([]indexItem, len(items))
+	copy(sorted, items)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].id < sorted[j].id
+	})
+	return sorted
+}
+
+// encodeHeader returns the header length, including the flags, followed by
+// the header data for the given items.
+func encodeHeader(items []indexItem, sorted bool) ([]byte, error) {
+	buffer := bytes.NewBuffer(make([]byte, 4))
+	for i := range items {
+		// first write the ID length and then the ID
+		if len(items[i].id) > MaxIDLen {
+			return nil, errors.New("ID is too long")
+		}
+		// writing to bytes.Buffer never returns error != nil so do not check it
+		binary.Write(buffer, byteOrder, uint16(len(items[i].id)))
+		buffer.WriteString(items[i].id)
+		binary.Write(buffer, byteOrder, items[i].end-items[i].start)
+	}
+	data := buffer.Bytes()
+	if len(data)-4 > maxHeaderLength {
+		return nil, errors.New("header is too long")
+	}
+	headerLength := uint32(len(data) - 4)
+	if sorted {
+		headerLength |= headerFlagSorted
+	}
+	byteOrder.PutUint32(data, headerLength)
+	return data, nil
+}
+
+// ReadOptions limit the resources that reading a blob may use. They protect
+// against malicious or broken input, e.g. a tiny file that claims to have a
+// header of a gigabyte. A zero value means no limit.
+type ReadOptions struct {
+	// MaxHeaderSize is the maximum header length in bytes.
+	MaxHeaderSize int64
+	// MaxItemCount is the maximum number of items in the header.
+	MaxItemCount int
+	// MaxDataSize is the maximum sum of all item sizes in bytes.
+	MaxDataSize int64
+	// Progress is called while reading, if it is not nil. It is only used by
+	// ReadWithOptions and ReadContext, which are the only functions that read
+	// all data.
+	Progress ProgressFunc
+}
+
+// readChunkSize is the amount of memory that is allocated up front when reading
+// data whose size is given by the input. Larger data is only allocated as it
+// actually arrives, so short input can not make us allocate a lot of memory.
+const readChunkSize = 1 << 20
+
+// readBytes reads exactly n bytes from r, growing the buffer as the data
+// arrives.
+func readBytes(r io.Reader, n uint64) ([]byte, error) {
+	if n > math.MaxInt {
+		return nil, errors.New("size is too large")
+	}
+	buf := make([]byte, min(n, readChunkSize))
+	filled := 0
+	for {
+		_, err := io.ReadFull(r, buf[filled:])
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return nil, err
+		}
+		if uint64(len(buf)) == n {
+			return buf, nil
+		}
+		// double the buffer size, at most up to n
+		filled = len(buf)
+		next := make([]byte, filled+int(min(uint64(filled), n-uint64(filled))))
+		copy(next, buf)
+		buf = next
+	}
+}
+
+func readHeader(r io.Reader, opts ReadOptions) (header, uint64, error) {
 	// read header length
 	var headerLength uint32
 	err := binary.Read(r, byteOrder, &headerLength)
 	if err != nil {
-		return nil, 0, errors.New("read blob header length: " + err.Error())
+		return header{}, 0, errors.New("read blob header length: " + err.Error())
+	}
+
+	flags := headerLength & headerFlagMask
+	headerLength &^= headerFlagMask
+	if flags == headerFlagTrailing && headerLength == 0 {
+		// the caller has to read the header at this offset
+		var offset uint64
+		err := binary.Read(r, byteOrder, &offset)
+		if err != nil {
+			return header{}, 0, errors.New("read blob index offset: " + err.Error())
+		}
+		if offset < trailingDataStart || offset > math.MaxInt64 {
+			return header{}, 0, errors.New("read blob index offset: invalid offset")
+		}
+		return header{indexOffset: offset}, 0, nil
+	}
+	if flags&^headerFlagSorted != 0 {
+		return header{}, 0, errors.New("read blob header length: unknown flags")
+	}
+	if opts.MaxHeaderSize > 0 && int64(headerLength) > opts.MaxHeaderSize {
+		return header{}, 0, errors.New("read blob header length: header exceeds maximum size")
+	}
+	h := header{
+		sorted: flags&headerFlagSorted != 0,
+		length: uint64(headerLength),
 	}
 
 	if headerLength == 0 {
-		return header{}, 0, nil
+		return h, 0, nil
 	}
 
 	// read the actual header
-	headerData := make([]byte, headerLength)
-	_, err = r.Read(headerData)
+	headerData, err := readBytes(r, uint64(headerLength))
 	if err != nil {
-		return nil, 0, errors.New("read blob header: " + err.Error())
+		return header{}, 0, errors.New("read blob header: " + err.Error())
 	}
 
 	// dissect the header, keeping track of the overall data length
@@ -164,27 +383,41 @@ func readHeader(r io.Reader) (header, uint64, error) {
 	var dataLength uint64
 	var idLength uint16
 	headerReader := bytes.NewBuffer(headerData)
-	var h header
 	for headerReader.Len() > 0 {
+		if opts.MaxItemCount > 0 && len(h.items) >= opts.MaxItemCount {
+			return header{}, 0, errors.New("read blob header: too many items")
+		}
+
 		err = binary.Read(headerReader, byteOrder, &idLength)
 		if err != nil {
-			return nil, 0, errors.New("read blob header id length: " + err.Error())
+			return header{}, 0, errors.New("read blob header id length: " + err.Error())
 		}
 
 		id := string(headerReader.Next(int(idLength)))
 		if len(id) != int(idLength) {
-			return nil, 0, errors.New("read blob header id: unexpected EOF")
+			return header{}, 0, errors.New("read blob header id: unexpected EOF")
 		}
 
 		err = binary.Read(headerReader, byteOrder, &dataLength)
 		if err != nil {
-			return nil, 0, errors.New("read blob header data length: " + err.Error())
+			return header{}, 0, errors.New("read blob header data length: " + err.Error())
+		}
+		if overallDataLength+dataLength < overallDataLength ||
+			overallDataLength+dataLength > math.MaxInt64 {
+			return header{}, 0, errors.New("read blob header data length: data is too large")
+		}
+		if opts.MaxDataSize > 0 && overallDataLength+dataLength > uint64(opts.MaxDataSize) {
+			return header{}, 0, errors.New("read blob header data length: data exceeds maximum size")
+		}
+
+		if h.sorted && len(h.items) > 0 && h.items[len(h.items)-1].id > id {
+			return header{}, 0, errors.New("read blob header: flagged as sorted but is not")
 		}
 
-		h = append(h, indexItem{
-			id,
-			overallDataLength,
-			overallDataLength + dataLength,
+		h.items = append(h.items, indexItem{
+			id:    id,
+			start: overallDataLength,
+			end:   overallDataLength + dataLength,
 		})
 
 		overallDataLength += dataLength
@@ -195,20 +428,47 @@ func readHeader(r io.Reader) (header, uint64, error) {
 // Read reads a binary blob from the given reader, keeping all data in memory.
 // If an error occurs, the returned blob will be nil. See Write for a
 // description of the data format.
+//
+// Read does not limit the size of the input, use ReadWithOptions for data from
+// untrusted sources.
 func Read(r io.Reader) (*Blob, error) {
+	return ReadWithOptions(r, ReadOptions{})
+}
+
+// ReadWithOptions reads a blob like Read but fails if the input exceeds the
+// limits given in opts.
+func ReadWithOptions(r io.Reader, opts ReadOptions) (*Blob, error) {
+	return ReadContext(context.Background(), r, opts)
+}
+
+// ReadContext reads a blob like ReadWithOptions but stops when ctx is
+// canceled. Cancellation is checked between items and while reading large
+// items. The returned error then wraps ctx.Err() and names the item that was
+// being read.
+func ReadContext(ctx context.Context, r io.Reader, opts ReadOptions) (*Blob, error) {
 	var b Blob
 	var overallDataLength uint64
 	var err error
-	b.header, overallDataLength, err = readHeader(r)
+	b.header, overallDataLength, err = readHeader(&contextReader{ctx, r}, opts)
+	if ctx.Err() != nil {
+		return nil, fmt.Errorf("read blob header: %w", ctx.Err())
+	}
 	if err != nil {
 		return nil, err
 	}
+	if b.indexOffset != 0 {
+		return readTrailing(ctx, r, b.indexOffset, opts)
+	}
+
+	if t := newTracker(ctx, opts.Progress, 4+int64(b.length), b.items); t != nil {
+		t.add(4 + int(b.length))
+		r = &trackingReader{r, t}
+	}
 
 	if overallDataLength > 0 {
-		b.data = make([]byte, overallDataLength)
-		_, err = io.ReadFull(r, b.data)
+		b.data, err = readBytes(r, overallDataLength)
 		if err != nil {
-			return nil, errors.New("read blob data: " + err.Error())
+			return nil, fmt.Errorf("read blob data: %w", err)
 		}
 	}
 
@@ -231,10 +491,29 @@ func Read(r io.Reader) (*Blob, error) {
 // You can not, however, read from r1 and r2 in parallel, e.g. in two different
 // Go routines since the underlying io.ReadSeeker is the same for both and in
 // each Read on r1 and r2, the position of r is set before reading.
+//
+// Open fails if the data of any item would lie beyond the end of r.
 func Open(r io.ReadSeeker) (*BlobReader, error) {
+	return OpenWithOptions(r, ReadOptions{})
+}
+
+// OpenWithOptions opens a blob like Open but fails if the header exceeds the
+// limits given in opts.
+func OpenWithOptions(r io.ReadSeeker, opts ReadOptions) (*BlobReader, error) {
+	return OpenContext(context.Background(), r, opts)
+}
+
+// OpenContext opens a blob like OpenWithOptions but stops reading the header
+// when ctx is canceled. The returned error then wraps ctx.Err(). The context
+// is only used while opening, not for reading items later on.
+func OpenContext(ctx context.Context, r io.ReadSeeker, opts ReadOptions) (*BlobReader, error) {
 	var err error
+	var overallDataLength uint64
 	b := BlobReader{r: r}
-	b.header, _, err = readHeader(r)
+	b.header, overallDataLength, err = readHeader(&contextReader{ctx, r}, opts)
+	if ctx.Err() != nil {
+		return nil, fmt.Errorf("read blob header: %w", ctx.Err())
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -244,6 +523,36 @@ func Open(r io.ReadSeeker) (*BlobReader, error) {
 		return nil, errors.New("open blob: " + err.Error())
 	}
 
+	if b.indexOffset != 0 {
+		// the item offsets count from the start of the blob, read the header
+		// at its end
+		b.zero -= trailingDataStart
+		_, err = r.Seek(b.zero+int64(b.indexOffset), io.SeekStart)
+		if err != nil {
+			return nil, errors.New("open blob: " + err.Error())
+		}
+		b.header, _, overallDataLength, err = readTrailingIndex(bufio.NewReader(&contextReader{ctx, r}), b.indexOffset, opts)
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("read blob header: %w", ctx.Err())
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// make sure that all items lie inside r
+	end, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, errors.New("open blob: " + err.Error())
+	}
+	if overallDataLength > uint64(end-b.zero) {
+		return nil, errors.New("open blob: data extends beyond the end of the input")
+	}
+	_, err = r.Seek(b.zero, io.SeekStart)
+	if err != nil {
+		return nil, errors.New("open blob: " + err.Error())
+	}
+
 	return &b, nil
 }
 
@@ -253,21 +562,19 @@ type BlobReader struct {
 	header
 	r    io.ReadSeeker
 	zero int64
+	// at is set if item readers use positional reads instead of seeking r,
+	// which makes them safe for parallel use.
+	at     io.ReaderAt
+	closer io.Closer
+	closed atomic.Bool
 }
 
 // GetByID searches the blob for an entry with the given ID and returns the
 // first one found. If there is no entry with the given ID, r will be nil and
 // found will be false.
 func (b *BlobReader) GetByID(id string) (r io.ReadSeeker, found bool) {
-	for i := range b.header {
-		if b.header[i].id == id {
-			return &reader{
-				file:  b.r,
-				start: b.zero + int64(b.header[i].start),
-				pos:   b.zero + int64(b.header[i].start),
-				end:   b.zero + int64(b.header[i].end),
-			}, true
-		}
+	if i, ok := b.find(id); ok {
+		return b.item(i), true
 	}
 	return nil, false
 }
@@ -276,34 +583,61 @@ func (b *BlobReader) GetByID(id string) (r io.ReadSeeker, found bool) {
 // bounds, r will be nil and found will be false. See ItemCount for the number
 // of items.
 func (b *BlobReader) GetByIndex(i int) (r io.ReadSeeker, found bool) {
-	if i < 0 || i >= len(b.header) {
+	if i < 0 || i >= len(b.items) {
 		return nil, false
 	}
+	return b.item(i), true
+}
+
+// item returns a reader for the data of the entry at the valid index i.
+func (b *BlobReader) item(i int) *reader {
 	return &reader{
-		file:  b.r,
-		start: b.zero + int64(b.header[i].start),
-		pos:   b.zero + int64(b.header[i].start),
-		end:   b.zero + int64(b.header[i].end),
-	}, true
+		owner: b,
+		start: b.zero + int64(b.items[i].start),
+		pos:   b.zero + int64(b.items[i].start),
+		end:   b.zero + int64(b.items[i].end),
+	}
+}
+
+// copyItem copies the data of the entry at the valid index i to w. Unlike
+// io.Copy, it fails if the underlying reader ends before the item does.
+func (b *BlobReader) copyItem(w io.Writer, i int) error {
+	_, err := io.CopyN(w, b.item(i), int64(b.items[i].end-b.items[i].start))
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return err
 }
 
 type reader struct {
-	file            io.ReadSeeker
+	owner           *BlobReader
 	start, pos, end int64
 }
 
 func (r *reader) Read(p []byte) (n int, err error) {
+	if r.owner.closed.Load() {
+		return 0, ErrClosed
+	}
 	if r.pos >= r.end {
 		return 0, io.EOF
 	}
 	if int64(len(p)) > r.end-r.pos {
 		p = p[:r.end-r.pos]
 	}
-	_, err = r.file.Seek(r.pos, io.SeekStart)
+	if r.owner.at != nil {
+		n, err = r.owner.at.ReadAt(p, r.pos)
+		r.pos += int64(n)
+		if n == len(p) {
+			// ReadAt may report EOF when reading up to the end of the file
+			err = nil
+		}
+		return
+	}
+	_, err = r.owner.r.Seek(r.pos, io.SeekStart)
 	if err != nil {
 		return 0, err
 	}
-	n, err = r.file.Read(p)
+	n, err = r.owner.r.Read(p)
 	r.pos += int64(n)
 	return
 }
@@ -326,7 +660,11 @@ func (r *reader) Seek(offset int64, whence int) (int64, error) {
 	if newPos > r.end {
 		newPos = r.end
 	}
+	if r.owner.at != nil {
+		r.pos = newPos
+		return r.pos - r.start, nil
+	}
 	var err error
-	r.pos, err = r.file.Seek(newPos, io.SeekStart)
+	r.pos, err = r.owner.r.Seek(newPos, io.SeekStart)
 	return r.pos - r.start, err
 }
@@ -0,0 +1,49 @@
+package blob
+
+import (
+	"bytes"
+	"errors"
+)
+
+// FromBytes parses a blob that is already in memory, e.g. embedded with
+// //go:embed. Unlike Read, it does not copy the data, the returned blob refers
+// to the given slice. The caller must not modify data afterwards.
+//
+// Appending to the returned blob never writes into data, it makes a copy
+// first. Like Read, FromBytes ignores any bytes after the blob.
+//
+// A blob that was changed with Edit and not compacted yet stores its items out
+// of order. Its data is copied.
+func FromBytes(data []byte) (*Blob, error) {
+	r := bytes.NewReader(data)
+	h, overallDataLength, err := readHeader(r, ReadOptions{})
+	if err != nil {
+		return nil, err
+	}
+	start := uint64(len(data) - r.Len())
+	if h.indexOffset != 0 {
+		return fromBytesTrailing(data[start-trailingDataStart:], h.indexOffset)
+	}
+	if overallDataLength > uint64(r.Len()) {
+		return nil, errors.New("read blob data: unexpected EOF")
+	}
+	end := start + overallDataLength
+	// limit the capacity so that Append has to re-allocate
+	return &Blob{header: h, data: data[start:end:end]}, nil
+}
+
+// fromBytesTrailing parses a blob with a trailing index, see Edit. Its items
+// are not stored in order, so they are copied into a new blob.
+func fromBytesTrailing(data []byte, indexOffset uint64) (*Blob, error) {
+	if indexOffset > uint64(len(data)) {
+		return nil, errors.New("read blob index: unexpected EOF")
+	}
+	h, _, end, err := readTrailingIndex(bytes.NewReader(data[indexOffset:]), indexOffset, ReadOptions{})
+	if err != nil {
+		return nil, err
+	}
+	if end > uint64(len(data)) {
+		return nil, errors.New("read blob data: unexpected EOF")
+	}
+	return fromTrailing(h, data, 0), nil
+}
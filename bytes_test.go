@@ -0,0 +1,53 @@
+package blob_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/gonutz/blob"
+)
+
+func TestFromBytesSharesTheInputBuffer(t *testing.T) {
+	b := blob.New()
+	b.Append("a", []byte("abc"))
+	data, _ := b.MarshalBinary()
+
+	parsed, err := blob.FromBytes(data)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkBlobContent(t, parsed, "a", "abc")
+	data[len(data)-1] = 'X'
+	checkBlobContent(t, parsed, "a", "abX")
+}
+
+func TestAppendAfterFromBytesDoesNotWriteIntoInput(t *testing.T) {
+	b := blob.New()
+	b.Append("a", []byte("a"))
+	var buf bytes.Buffer
+	b.Write(&buf)
+	buf.WriteString("after")
+	data := buf.Bytes()
+
+	parsed, err := blob.FromBytes(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed.Append("b", []byte("b"))
+
+	if !bytes.HasSuffix(data, []byte("after")) {
+		t.Error("Append overwrote the input buffer")
+	}
+	checkBlobContent(t, parsed, "a", "a", "b", "b")
+}
+
+func TestFromBytesFailsOnTruncatedData(t *testing.T) {
+	b := blob.New()
+	b.Append("a", []byte("abc"))
+	data, _ := b.MarshalBinary()
+
+	if _, err := blob.FromBytes(data[:len(data)-1]); err == nil {
+		t.Error("error expected")
+	}
+}
@@ -0,0 +1,241 @@
+package blob
+
+import (
+	"container/list"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// ErrNotFound is returned if a blob has no item with the requested ID or
+// index. It is usually wrapped in an error that names the ID, use errors.Is to
+// check for it.
+var ErrNotFound = errors.New("blob: item not found")
+
+// Cache keeps recently used items of a BlobReader in memory. It sits between a
+// Blob, which keeps all data in memory, and a BlobReader, which reads the data
+// anew for every access.
+//
+// The cache holds at most a given number of bytes. When an item is loaded that
+// does not fit, the least recently used items are dropped until it does. Items
+// that are larger than the whole budget are returned but not kept. Pinned items
+// are never dropped, they count towards the budget but may exceed it.
+//
+// A Cache is safe for concurrent use. It is the only user of the underlying
+// BlobReader while loading, so the restriction described on Open does not apply
+// as long as the BlobReader is not used directly at the same time. Items are
+// loaded without blocking requests for items that are in memory, and an item
+// that is requested while it is loading is only read once.
+type Cache struct {
+	r        *BlobReader
+	maxBytes int64
+
+	mu      sync.Mutex
+	lru     *list.List // of *cacheEntry, most recently used first
+	entries map[int]*list.Element
+	loads   map[int]*cacheLoad // items that are being read
+	size    int64
+	hits    int64
+	misses  int64
+
+	// readMu serializes reads from r if its item readers share an
+	// io.ReadSeeker.
+	readMu sync.Mutex
+}
+
+// cacheLoad is an item that is being read from the BlobReader. Requests for it
+// wait until done is closed.
+type cacheLoad struct {
+	done chan struct{}
+	data []byte
+	err  error
+}
+
+type cacheEntry struct {
+	index  int
+	data   []byte
+	pinned bool
+}
+
+// CacheStats describes the usage of a Cache.
+type CacheStats struct {
+	// Hits counts the requests that were served from memory.
+	Hits int64
+	// Misses counts the requests that had to read from the BlobReader.
+	Misses int64
+	// Items is the number of items currently in memory.
+	Items int
+	// Bytes is the sum of the sizes of all items currently in memory.
+	Bytes int64
+}
+
+// NewCache creates a cache for the items of r that keeps at most maxBytes of
+// item data in memory, not counting pinned items that exceed it.
+func NewCache(r *BlobReader, maxBytes int64) *Cache {
+	return &Cache{
+		r:        r,
+		maxBytes: maxBytes,
+		lru:      list.New(),
+		entries:  make(map[int]*list.Element),
+		loads:    make(map[int]*cacheLoad),
+	}
+}
+
+// ItemCount returns the number of items in the underlying BlobReader.
+func (c *Cache) ItemCount() int {
+	return c.r.ItemCount()
+}
+
+// GetIDAtIndex returns the ID of the entry at index i or the empty string if
+// the given index is out of bounds.
+func (c *Cache) GetIDAtIndex(i int) string {
+	return c.r.GetIDAtIndex(i)
+}
+
+// GetByID returns the data of the first entry with the given ID, from memory
+// if possible. The returned slice is shared with the cache and must not be
+// modified. If there is no such entry, the error wraps ErrNotFound.
+func (c *Cache) GetByID(id string) ([]byte, error) {
+	return c.getByID("blob.Cache.GetByID", id)
+}
+
+// getByID implements GetByID, context prefixes the error messages.
+func (c *Cache) getByID(context, id string) ([]byte, error) {
+	i, found := c.r.find(id)
+	if !found {
+		return nil, notFoundError(context, id)
+	}
+	data, err := c.get(i, false)
+	if err != nil {
+		return nil, itemError(context, id, err)
+	}
+	return data, nil
+}
+
+// GetByIndex returns the data of the entry at index i, from memory if
+// possible. The returned slice is shared with the cache and must not be
+// modified. If the index is out of bounds, the error wraps ErrNotFound.
+func (c *Cache) GetByIndex(i int) ([]byte, error) {
+	if i < 0 || i >= c.r.ItemCount() {
+		return nil, fmt.Errorf("blob.Cache.GetByIndex: %w: index %d", ErrNotFound, i)
+	}
+	data, err := c.get(i, false)
+	if err != nil {
+		return nil, itemError("blob.Cache.GetByIndex", c.r.items[i].id, err)
+	}
+	return data, nil
+}
+
+// Pin loads the first entry with the given ID into memory and keeps it there
+// until Unpin is called, regardless of the cache's byte budget.
+func (c *Cache) Pin(id string) error {
+	i, found := c.r.find(id)
+	if !found {
+		return notFoundError("blob.Cache.Pin", id)
+	}
+	if _, err := c.get(i, true); err != nil {
+		return itemError("blob.Cache.Pin", id, err)
+	}
+	return nil
+}
+
+// Unpin makes the entry with the given ID subject to eviction again. It does
+// nothing if the entry is not pinned.
+func (c *Cache) Unpin(id string) {
+	i, found := c.r.find(id)
+	if !found {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if e, ok := c.entries[i]; ok {
+		e.Value.(*cacheEntry).pinned = false
+		c.evict()
+	}
+}
+
+// Stats returns the current hit and miss counters and memory usage.
+func (c *Cache) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return CacheStats{
+		Hits:   c.hits,
+		Misses: c.misses,
+		Items:  c.lru.Len(),
+		Bytes:  c.size,
+	}
+}
+
+func (c *Cache) get(i int, pin bool) ([]byte, error) {
+	c.mu.Lock()
+	if e, ok := c.entries[i]; ok {
+		c.hits++
+		c.lru.MoveToFront(e)
+		entry := e.Value.(*cacheEntry)
+		entry.pinned = entry.pinned || pin
+		c.mu.Unlock()
+		return entry.data, nil
+	}
+	c.misses++
+	load, loading := c.loads[i]
+	if !loading {
+		load = &cacheLoad{done: make(chan struct{})}
+		c.loads[i] = load
+	}
+	c.mu.Unlock()
+
+	if loading {
+		<-load.done
+		if load.err == nil && pin {
+			// the loading request may not have kept the data, pin it now
+			return c.get(i, pin)
+		}
+		return load.data, load.err
+	}
+
+	load.data, load.err = c.read(i)
+	c.mu.Lock()
+	delete(c.loads, i)
+	if load.err == nil && (pin || int64(len(load.data)) <= c.maxBytes) {
+		c.entries[i] = c.lru.PushFront(&cacheEntry{index: i, data: load.data, pinned: pin})
+		c.size += int64(len(load.data))
+		c.evict()
+	}
+	c.mu.Unlock()
+	close(load.done)
+	return load.data, load.err
+}
+
+// read reads the data of the item at index i from the BlobReader.
+func (c *Cache) read(i int) ([]byte, error) {
+	if c.r.serialReads() {
+		c.readMu.Lock()
+		defer c.readMu.Unlock()
+	}
+	item := c.r.item(i)
+	data := make([]byte, item.end-item.start)
+	if _, err := io.ReadFull(item, data); err != nil {
+		return nil, fmt.Errorf("read: %w", err)
+	}
+	return data, nil
+}
+
+// evict drops the least recently used entries that are not pinned until the
+// cache is within its budget.
+func (c *Cache) evict() {
+	e := c.lru.Back()
+	for c.size > c.maxBytes && e != nil {
+		prev := e.Prev()
+		entry := e.Value.(*cacheEntry)
+		if !entry.pinned {
+			c.lru.Remove(e)
+			delete(c.entries, entry.index)
+			c.size -= int64(len(entry.data))
+		}
+		e = prev
+	}
+}
@@ -0,0 +1,193 @@
+package blob_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gonutz/blob"
+)
+
+func checkStats(t *testing.T, c *blob.Cache, want blob.CacheStats) {
+	t.Helper()
+	if have := c.Stats(); have != want {
+		t.Errorf("want stats %+v but have %+v", want, have)
+	}
+}
+
+func TestCacheCountsHitsAndMisses(t *testing.T) {
+	b := blob.New()
+	b.Append("a", []byte{1, 2, 3, 4})
+	c := blob.NewCache(openBlob(t, b), 10)
+
+	data, err := c.GetByID("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkBytes(t, data, []byte{1, 2, 3, 4})
+	checkStats(t, c, blob.CacheStats{Misses: 1, Items: 1, Bytes: 4})
+
+	data, err = c.GetByIndex(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkBytes(t, data, []byte{1, 2, 3, 4})
+	checkStats(t, c, blob.CacheStats{Hits: 1, Misses: 1, Items: 1, Bytes: 4})
+}
+
+func TestCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	b := blob.New()
+	b.Append("a", []byte{1, 2, 3, 4})
+	b.Append("b", []byte{5, 6, 7, 8})
+	b.Append("c", []byte{9, 10, 11, 12})
+	c := blob.NewCache(openBlob(t, b), 8)
+
+	c.GetByID("a")
+	c.GetByID("b")
+	c.GetByID("a") // now b is the least recently used
+	c.GetByID("c") // evicts b
+	checkStats(t, c, blob.CacheStats{Hits: 1, Misses: 3, Items: 2, Bytes: 8})
+
+	c.GetByID("a")
+	checkStats(t, c, blob.CacheStats{Hits: 2, Misses: 3, Items: 2, Bytes: 8})
+	c.GetByID("b")
+	checkStats(t, c, blob.CacheStats{Hits: 2, Misses: 4, Items: 2, Bytes: 8})
+}
+
+func TestCacheDoesNotKeepItemsLargerThanBudget(t *testing.T) {
+	b := blob.New()
+	b.Append("big", make([]byte, 100))
+	c := blob.NewCache(openBlob(t, b), 8)
+
+	data, err := c.GetByID("big")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != 100 {
+		t.Error("want 100 bytes but have", len(data))
+	}
+	checkStats(t, c, blob.CacheStats{Misses: 1})
+}
+
+func TestPinnedItemsAreNotEvicted(t *testing.T) {
+	b := blob.New()
+	b.Append("a", []byte{1, 2, 3, 4})
+	b.Append("b", []byte{5, 6, 7, 8})
+	b.Append("c", []byte{9, 10, 11, 12})
+	b.Append("big", make([]byte, 100))
+	c := blob.NewCache(openBlob(t, b), 8)
+
+	if err := c.Pin("a"); err != nil {
+		t.Fatal(err)
+	}
+	c.GetByID("b")
+	c.GetByID("c") // evicts b, not a
+	checkStats(t, c, blob.CacheStats{Misses: 3, Items: 2, Bytes: 8})
+	c.GetByID("a")
+	checkStats(t, c, blob.CacheStats{Hits: 1, Misses: 3, Items: 2, Bytes: 8})
+
+	if err := c.Pin("big"); err != nil {
+		t.Fatal(err)
+	}
+	checkStats(t, c, blob.CacheStats{Hits: 1, Misses: 4, Items: 2, Bytes: 104})
+
+	c.Unpin("big")
+	checkStats(t, c, blob.CacheStats{Hits: 1, Misses: 4, Items: 1, Bytes: 4})
+}
+
+func TestCacheReportsUnknownItems(t *testing.T) {
+	b := blob.New()
+	b.Append("a", nil)
+	c := blob.NewCache(openBlob(t, b), 8)
+
+	if _, err := c.GetByID("x"); !errors.Is(err, blob.ErrNotFound) {
+		t.Error("want ErrNotFound but have", err)
+	}
+	if _, err := c.GetByIndex(1); !errors.Is(err, blob.ErrNotFound) {
+		t.Error("want ErrNotFound but have", err)
+	}
+	if err := c.Pin("x"); !errors.Is(err, blob.ErrNotFound) {
+		t.Error("want ErrNotFound but have", err)
+	}
+}
+
+func TestCacheOverClosedReaderReportsErrClosed(t *testing.T) {
+	b := blob.New()
+	b.Append("a", []byte("data"))
+	r, err := blob.OpenPath(writeBlobFile(t, b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := blob.NewCache(r, 100)
+	r.Close()
+
+	_, err = c.GetByID("a")
+
+	if !errors.Is(err, blob.ErrClosed) || !strings.HasPrefix(err.Error(), "blob.Cache.GetByID: ") {
+		t.Error("want ErrClosed from blob.Cache.GetByID but have", err)
+	}
+	if _, err := c.Open("a"); !errors.Is(err, blob.ErrClosed) {
+		t.Error("want ErrClosed but have", err)
+	}
+}
+
+// gatedReadSeeker blocks every Read while blocked is set, until the gate is
+// opened.
+type gatedReadSeeker struct {
+	io.ReadSeeker
+	blocked atomic.Bool
+	gate    chan struct{}
+}
+
+func (r *gatedReadSeeker) Read(p []byte) (int, error) {
+	if r.blocked.Load() {
+		<-r.gate
+	}
+	return r.ReadSeeker.Read(p)
+}
+
+func TestCacheServesHitsWhileLoading(t *testing.T) {
+	b := blob.New()
+	b.Append("hot", []byte("hot"))
+	b.Append("cold", []byte("cold"))
+	var buf bytes.Buffer
+	b.Write(&buf)
+	r := &gatedReadSeeker{ReadSeeker: bytes.NewReader(buf.Bytes()), gate: make(chan struct{})}
+	br, err := blob.Open(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := blob.NewCache(br, 100)
+	c.GetByID("hot")
+
+	r.blocked.Store(true)
+	cold := make(chan []byte, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			data, _ := c.GetByID("cold")
+			cold <- data
+		}()
+	}
+	hot := make(chan []byte)
+	go func() {
+		data, _ := c.GetByID("hot")
+		hot <- data
+	}()
+	select {
+	case data := <-hot:
+		checkBytes(t, data, []byte("hot"))
+	case <-time.After(5 * time.Second):
+		t.Error("a cache hit waited for loading another item")
+	}
+
+	close(r.gate)
+	checkBytes(t, <-cold, []byte("cold"))
+	checkBytes(t, <-cold, []byte("cold"))
+	if stats := c.Stats(); stats.Items != 2 {
+		t.Error("want 2 items in memory but have", stats.Items)
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/gonutz/blob"
+)
+
+func isArchive(path string) bool {
+	path = strings.ToLower(path)
+	for _, ext := range []string{".tar", ".tar.gz", ".tgz", ".zip"} {
+		if strings.HasSuffix(path, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// readArchive converts the tar or zip archive at path to a blob. It stops
+// reading when ctx is canceled.
+func readArchive(ctx context.Context, path string) (*blob.Blob, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	r := &contextFile{ctx: ctx, f: f}
+
+	if strings.HasSuffix(strings.ToLower(path), ".zip") {
+		info, err := f.Stat()
+		if err != nil {
+			return nil, err
+		}
+		z, err := zip.NewReader(r, info.Size())
+		if err != nil {
+			return nil, err
+		}
+		return blob.FromZip(z)
+	}
+	if strings.HasSuffix(strings.ToLower(path), ".tar") {
+		return blob.FromTar(r)
+	}
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+	b, err := blob.FromTar(gz)
+	if err != nil {
+		return nil, err
+	}
+	// The gzip reader only checks its trailer checksum at the end of the
+	// stream, which the tar reader does not necessarily reach.
+	if _, err := io.Copy(io.Discard, gz); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// contextFile reads from f until ctx is canceled.
+type contextFile struct {
+	ctx context.Context
+	f   *os.File
+}
+
+func (r *contextFile) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.f.Read(p)
+}
+
+func (r *contextFile) ReadAt(p []byte, off int64) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.f.ReadAt(p, off)
+}
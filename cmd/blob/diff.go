@@ -0,0 +1,130 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/gonutz/blob"
+)
+
+func runDiff(args []string) int {
+	flags := flag.NewFlagSet("blob diff", flag.ContinueOnError)
+	out := flags.String("out", "", "Output path of the patch")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), `blob diff creates a patch that turns blob file old into blob file new.
+
+Use blob patch to apply it.
+
+Usage of blob diff:
+  blob diff -out patch old new
+`)
+		flags.PrintDefaults()
+	}
+	if !parseTwoFileFlags(flags, args, out) {
+		return 1
+	}
+
+	old, err := openBlobFile(flags.Arg(0))
+	if err != nil {
+		errln(err.Error())
+		return 1
+	}
+	defer old.Close()
+	new, err := openBlobFile(flags.Arg(1))
+	if err != nil {
+		errln(err.Error())
+		return 1
+	}
+	defer new.Close()
+
+	// the output may replace an input file, close them before it is renamed
+	// over, which fails for open files on Windows
+	err = blob.AtomicWriteFile(*out, false, func(w io.Writer) error {
+		if err := blob.Diff(old, new, w); err != nil {
+			return err
+		}
+		if err := old.Close(); err != nil {
+			return err
+		}
+		return new.Close()
+	})
+	if err != nil {
+		errln("unable to create patch: " + err.Error())
+		return 1
+	}
+	return 0
+}
+
+func runPatch(args []string) int {
+	flags := flag.NewFlagSet("blob patch", flag.ContinueOnError)
+	out := flags.String("out", "", "Output path of the new blob file")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), `blob patch applies a patch created by blob diff to blob file old.
+
+Usage of blob patch:
+  blob patch -out new old patch
+`)
+		flags.PrintDefaults()
+	}
+	if !parseTwoFileFlags(flags, args, out) {
+		return 1
+	}
+
+	old, err := openBlobFile(flags.Arg(0))
+	if err != nil {
+		errln(err.Error())
+		return 1
+	}
+	defer old.Close()
+	patch, err := os.Open(flags.Arg(1))
+	if err != nil {
+		errln("unable to open patch file: " + err.Error())
+		return 1
+	}
+	defer patch.Close()
+
+	// the output may replace the old file, close it before it is renamed
+	// over, which fails for open files on Windows
+	err = blob.AtomicWriteFile(*out, false, func(w io.Writer) error {
+		if err := blob.Patch(old, patch, w); err != nil {
+			return err
+		}
+		return old.Close()
+	})
+	if err != nil {
+		errln("unable to apply patch: " + err.Error())
+		return 1
+	}
+	return 0
+}
+
+// parseTwoFileFlags parses the arguments of a subcommand that takes two input
+// files and writes one output file. It returns false if the arguments are
+// invalid.
+func parseTwoFileFlags(flags *flag.FlagSet, args []string, out *string) bool {
+	if err := flags.Parse(args); err != nil {
+		return false
+	}
+	if *out == "" {
+		errln("output path not specified")
+		flags.Usage()
+		return false
+	}
+	if flags.NArg() != 2 {
+		errln("expected exactly two input files")
+		flags.Usage()
+		return false
+	}
+	return true
+}
+
+// openBlobFile opens the blob file at path. Close the returned blob when done.
+func openBlobFile(path string) (*blob.BlobReader, error) {
+	b, err := blob.OpenPath(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read blob file '%s': %v", path, err)
+	}
+	return b, nil
+}
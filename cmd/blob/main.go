@@ -1,17 +1,22 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"github.com/gonutz/blob"
-	"io/ioutil"
 	"os"
+	"os/signal"
 	"path/filepath"
 )
 
 var (
-	inPath  = flag.String("path", "", "File or folder to be blobbed")
-	outPath = flag.String("out", "", "Output path")
+	inPath    = flag.String("path", "", "File, folder or archive to be blobbed")
+	outPath   = flag.String("out", "", "Output path")
+	sortIDs   = flag.Bool("sort", false, "Sort the items by ID for faster lookups")
+	backup    = flag.Bool("backup", false, "Keep the previous output file as <out>.bak")
+	splitSize = flag.Int64("split-size", 0, `Split the output into files of at most this many bytes, named
+<out>.000, <out>.001, ...`)
 )
 
 func main() {
@@ -19,6 +24,23 @@ func main() {
 }
 
 func runMain() int {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "serve":
+			return runServe(os.Args[2:])
+		case "merge":
+			return runMerge(os.Args[2:])
+		case "fromwad":
+			return runFromWAD(os.Args[2:])
+		case "towad":
+			return runToWAD(os.Args[2:])
+		case "diff":
+			return runDiff(os.Args[2:])
+		case "patch":
+			return runPatch(os.Args[2:])
+		}
+	}
+
 	flag.Usage = func() {
 		fmt.Fprintf(flag.CommandLine.Output(), `blob takes a file or folder and creates a binary blob file of it.
 
@@ -36,6 +58,17 @@ Example: the following file structure
 results in the following IDs: "index.html", "static/favicon.ico",
 "static/logo.png".
 
+If you blob a .tar, .tar.gz, .tgz or .zip archive, all regular files in it will
+be blobbed. The IDs are the paths inside the archive, just like for a folder.
+
+Subcommands:
+  blob serve [-addr address] file    serve the items of a blob file over HTTP
+  blob merge -out file files...      combine several blob files into one
+  blob fromwad -out file wadfile     convert a Doom IWAD or PWAD to a blob file
+  blob towad -out wadfile file       convert a blob file to a Doom PWAD
+  blob diff -out patch old new       create a patch from blob file old to new
+  blob patch -out new old patch      apply a patch to blob file old
+
 Usage of blob:
 `)
 		flag.PrintDefaults()
@@ -52,6 +85,11 @@ Usage of blob:
 		flag.Usage()
 		return 1
 	}
+	if *splitSize > 0 && *backup {
+		errln("-backup can not be used with -split-size")
+		flag.Usage()
+		return 1
+	}
 
 	f, err := os.Lstat(*inPath)
 	if err != nil {
@@ -59,45 +97,58 @@ Usage of blob:
 		return 1
 	}
 
-	var b blob.Blob
+	// stop on Ctrl+C, while reading the input as well as while writing the
+	// output
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	b := blob.New()
 	if f.IsDir() {
-		err := filepath.Walk(*inPath, func(path string, info os.FileInfo, err error) error {
-			if err != nil {
-				return err
-			}
-			if !info.IsDir() {
-				// add this file
-				data, err := ioutil.ReadFile(path)
-				if err != nil {
-					return err
-				}
-				relPath, _ := filepath.Rel(*inPath, path)
-				id := filepath.ToSlash(relPath)
-				b.Append(id, data)
-			}
-			return nil
-		})
+		dir, err := blob.DirSourceContext(ctx, *inPath)
 		if err != nil {
 			errln("unable to traverse input directory: " + err.Error())
 			return 1
 		}
-	} else {
-		data, err := ioutil.ReadFile(*inPath)
+		// the files are only read while writing, one at a time
+		for i := 0; i < dir.ItemCount(); i++ {
+			id := dir.GetIDAtIndex(i)
+			path := filepath.Join(*inPath, filepath.FromSlash(id))
+			if err := b.AppendFile(id, path); err != nil {
+				errln("unable to read input file: " + err.Error())
+				return 1
+			}
+		}
+	} else if isArchive(*inPath) {
+		b, err = readArchive(ctx, *inPath)
 		if err != nil {
+			errln("unable to read input archive: " + err.Error())
+			return 1
+		}
+	} else {
+		if err := b.AppendFile(filepath.Base(*inPath), *inPath); err != nil {
 			errln("unable to read input file: " + err.Error())
 			return 1
 		}
-		b.Append(filepath.Base(*inPath), data)
 	}
 
-	outFile, err := os.Create(*outPath)
-	if err != nil {
-		errln("unable to create output file: " + err.Error())
-		return 1
+	progress, finishProgress := newProgress()
+	opts := blob.WriteOptions{SortIDs: *sortIDs, Progress: progress}
+	if *splitSize > 0 {
+		// existing volumes are only replaced once all new ones were written
+		err := b.WriteVolumesContext(ctx, *outPath, *splitSize, opts)
+		finishProgress()
+		if err != nil {
+			errln("unable to write output volumes: " + err.Error())
+			return 1
+		}
+		return 0
 	}
-	defer outFile.Close()
 
-	if err := b.Write(outFile); err != nil {
+	// the output file is replaced atomically, it is never left half-written
+	opts.Backup = *backup
+	err = b.WriteFileContext(ctx, *outPath, opts)
+	finishProgress()
+	if err != nil {
 		errln("unable to write output file: " + err.Error())
 		return 1
 	}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/gonutz/blob"
+)
+
+func runMerge(args []string) int {
+	flags := flag.NewFlagSet("blob merge", flag.ContinueOnError)
+	out := flags.String("out", "", "Output path")
+	policy := flags.String("policy", "first", `Which item to keep if an ID is in more than one input:
+"first", "last" or "error" to fail`)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), `blob merge combines the items of several blob files into one.
+
+Each ID appears only once in the output, the -policy decides which input it is
+taken from.
+
+Usage of blob merge:
+  blob merge [-policy first|last|error] -out file files...
+`)
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 1
+	}
+	if *out == "" {
+		errln("output path not specified")
+		flags.Usage()
+		return 1
+	}
+	policies := map[string]blob.ConflictPolicy{
+		"first": blob.FirstWins,
+		"last":  blob.LastWins,
+		"error": blob.ErrorOnConflict,
+	}
+	conflictPolicy, ok := policies[*policy]
+	if !ok {
+		errln("unknown policy '" + *policy + "'")
+		flags.Usage()
+		return 1
+	}
+
+	var srcs []*blob.BlobReader
+	for _, path := range flags.Args() {
+		b, err := openBlobFile(path)
+		if err != nil {
+			errln(err.Error())
+			return 1
+		}
+		defer b.Close()
+		srcs = append(srcs, b)
+	}
+
+	// the output may replace an input file, close them before it is renamed
+	// over, which fails for open files on Windows
+	err := blob.AtomicWriteFile(*out, false, func(w io.Writer) error {
+		if err := blob.MergeWithPolicy(w, conflictPolicy, srcs...); err != nil {
+			return err
+		}
+		for _, src := range srcs {
+			if err := src.Close(); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		errln("unable to merge: " + err.Error())
+		return 1
+	}
+	return 0
+}
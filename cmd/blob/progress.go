@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/gonutz/blob"
+)
+
+// progressBar draws the progress of writing a blob on stderr.
+type progressBar struct {
+	percent int
+	id      string
+	drawn   bool
+}
+
+// newProgress returns a progress bar's update function if stderr is a
+// terminal and nil otherwise, so nothing is printed into redirected output.
+// Call the returned finish function when done.
+func newProgress() (progress blob.ProgressFunc, finish func()) {
+	info, err := os.Stderr.Stat()
+	if err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return nil, func() {}
+	}
+	bar := &progressBar{percent: -1}
+	return bar.update, bar.finish
+}
+
+func (b *progressBar) update(done, total int64, id string) {
+	percent := 100
+	if total > 0 {
+		percent = int(done * 100 / total)
+	}
+	if percent == b.percent && id == b.id {
+		return
+	}
+	b.percent = percent
+	b.id = id
+	b.drawn = true
+
+	const width = 30
+	filled := percent * width / 100
+	if len(id) > 40 {
+		id = "..." + id[len(id)-37:]
+	}
+	// \x1b[K clears the rest of the line
+	fmt.Fprintf(os.Stderr, "\r[%s%s] %3d%% %s\x1b[K",
+		strings.Repeat("#", filled), strings.Repeat(" ", width-filled), percent, id)
+}
+
+func (b *progressBar) finish() {
+	if b.drawn {
+		fmt.Fprintln(os.Stderr)
+	}
+}
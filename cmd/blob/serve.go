@@ -0,0 +1,47 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+
+	"github.com/gonutz/blob"
+)
+
+func runServe(args []string) int {
+	flags := flag.NewFlagSet("blob serve", flag.ContinueOnError)
+	addr := flags.String("addr", "localhost:8080", "Address to listen on")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), `blob serve serves the items of a blob file over HTTP for local preview.
+
+URL paths are mapped to IDs, a path ending in a slash serves the "index.html"
+in that directory.
+
+Usage of blob serve:
+  blob serve [-addr address] file
+`)
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 1
+	}
+	if flags.NArg() != 1 {
+		errln("expected exactly one blob file")
+		flags.Usage()
+		return 1
+	}
+
+	b, err := openBlobFile(flags.Arg(0))
+	if err != nil {
+		errln(err.Error())
+		return 1
+	}
+	defer b.Close()
+
+	fmt.Println("serving " + flags.Arg(0) + " on http://" + *addr)
+	if err := http.ListenAndServe(*addr, blob.Handler(b)); err != nil {
+		errln("unable to serve: " + err.Error())
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/gonutz/blob"
+)
+
+func runFromWAD(args []string) int {
+	flags := flag.NewFlagSet("blob fromwad", flag.ContinueOnError)
+	out := flags.String("out", "", "Output path")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), `blob fromwad converts a Doom IWAD or PWAD file to a blob file.
+
+The lump names become the IDs, in directory order.
+
+Usage of blob fromwad:
+  blob fromwad -out file wadfile
+`)
+		flags.PrintDefaults()
+	}
+	in, ok := parseConvertFlags(flags, args, out)
+	if !ok {
+		return 1
+	}
+
+	f, err := os.Open(in)
+	if err != nil {
+		errln("unable to open input file: " + err.Error())
+		return 1
+	}
+	defer f.Close()
+
+	b, err := blob.FromWAD(f)
+	if err != nil {
+		errln("unable to read WAD file: " + err.Error())
+		return 1
+	}
+
+	if err := b.WriteFile(*out, blob.WriteOptions{}); err != nil {
+		errln("unable to write output file: " + err.Error())
+		return 1
+	}
+	return 0
+}
+
+func runToWAD(args []string) int {
+	flags := flag.NewFlagSet("blob towad", flag.ContinueOnError)
+	out := flags.String("out", "", "Output path")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), `blob towad converts a blob file to a Doom PWAD file.
+
+The IDs become the lump names, they are made upper case and cut to 8
+characters.
+
+Usage of blob towad:
+  blob towad -out wadfile file
+`)
+		flags.PrintDefaults()
+	}
+	in, ok := parseConvertFlags(flags, args, out)
+	if !ok {
+		return 1
+	}
+
+	f, err := os.Open(in)
+	if err != nil {
+		errln("unable to open input file: " + err.Error())
+		return 1
+	}
+	defer f.Close()
+
+	b, err := blob.Read(f)
+	if err != nil {
+		errln("unable to read blob file: " + err.Error())
+		return 1
+	}
+
+	if err := blob.AtomicWriteFile(*out, false, b.WriteWAD); err != nil {
+		errln("unable to write WAD file: " + err.Error())
+		return 1
+	}
+	return 0
+}
+
+// parseConvertFlags parses the arguments of a subcommand that converts one
+// input file to one output file. It returns the input path and false if the
+// arguments are invalid.
+func parseConvertFlags(flags *flag.FlagSet, args []string, out *string) (string, bool) {
+	if err := flags.Parse(args); err != nil {
+		return "", false
+	}
+	if *out == "" {
+		errln("output path not specified")
+		flags.Usage()
+		return "", false
+	}
+	if flags.NArg() != 1 {
+		errln("expected exactly one input file")
+		flags.Usage()
+		return "", false
+	}
+	return flags.Arg(0), true
+}
@@ -0,0 +1,95 @@
+package blob_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/gonutz/blob"
+)
+
+func TestWriteContextStopsInsideLargeItem(t *testing.T) {
+	b := blob.New()
+	b.Append("large", bytes.Repeat([]byte{1}, 3<<20))
+	b.Append("last", []byte("last"))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var written int64
+
+	err := b.WriteContext(ctx, io.Discard, blob.WriteOptions{
+		Progress: func(done, total int64, id string) {
+			written = done
+			if id == "large" {
+				cancel()
+			}
+		},
+	})
+
+	if !errors.Is(err, context.Canceled) {
+		t.Fatal("want context.Canceled but have", err)
+	}
+	if !strings.Contains(err.Error(), `"large"`) {
+		t.Error("error must name the item but is", err)
+	}
+	if written >= b.Size() {
+		t.Error("writing must stop early but wrote everything")
+	}
+}
+
+func TestWriteContextStopsInsideLazyItem(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	b := blob.New()
+	b.AppendReader("lazy", bytes.NewReader(make([]byte, 3<<20)), 3<<20)
+
+	err := b.WriteContext(ctx, io.Discard, blob.WriteOptions{
+		Progress: func(done, total int64, id string) {
+			if id == "lazy" {
+				cancel()
+			}
+		},
+	})
+
+	if !errors.Is(err, context.Canceled) || strings.Count(err.Error(), `"lazy"`) != 1 {
+		t.Error("want context.Canceled naming the item once but have", err)
+	}
+}
+
+func TestReadContextAndOpenContextStopWhenCanceled(t *testing.T) {
+	b := blob.New()
+	b.Append("a", []byte("data"))
+	data, _ := b.MarshalBinary()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := blob.ReadContext(ctx, bytes.NewReader(data), blob.ReadOptions{}); !errors.Is(err, context.Canceled) {
+		t.Error("ReadContext: want context.Canceled but have", err)
+	}
+	if _, err := blob.OpenContext(ctx, bytes.NewReader(data), blob.ReadOptions{}); !errors.Is(err, context.Canceled) {
+		t.Error("OpenContext: want context.Canceled but have", err)
+	}
+}
+
+func TestReadContextNamesItemBeingRead(t *testing.T) {
+	b := blob.New()
+	b.Append("small", []byte("small"))
+	b.Append("large", bytes.Repeat([]byte{1}, 3<<20))
+	data, _ := b.MarshalBinary()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	_, err := blob.ReadContext(ctx, bytes.NewReader(data), blob.ReadOptions{
+		Progress: func(done, total int64, id string) {
+			if id == "small" {
+				cancel()
+			}
+		},
+	})
+
+	if !errors.Is(err, context.Canceled) || !strings.Contains(err.Error(), `"large"`) {
+		t.Error("want context.Canceled naming the next item but have", err)
+	}
+}
@@ -0,0 +1,234 @@
+package blob
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Dir is a Source that reads its items directly from the files in a folder,
+// see DirSource. It is meant for development: assets can be changed on disk
+// without re-building the blob, and for a release the Dir is replaced by a Blob
+// or BlobReader of the same folder.
+//
+// A Dir is safe for concurrent use. Its item readers are separate files, they
+// can be used in parallel.
+type Dir struct {
+	root string
+
+	mu    sync.RWMutex
+	files []dirFile
+	index map[string]int // ID to index in files
+}
+
+type dirFile struct {
+	id      string
+	size    int64
+	modTime time.Time
+}
+
+// DirSource lists all files under the folder root, recursively, and exposes
+// them as a Source. The IDs are derived like the blob command line tool does
+// it: they are the file paths relative to root, with slash as the separator,
+// in lexical order.
+//
+// The listing is made once, call Refresh or Watch to pick up added and removed
+// files. The data is always read from disk, changes to a listed file are
+// visible right away. Handler computes a new ETag when a file's size or
+// modification time changes.
+func DirSource(root string) (*Dir, error) {
+	return DirSourceContext(context.Background(), root)
+}
+
+// DirSourceContext lists the files like DirSource but stops when ctx is
+// canceled. Cancellation is checked between the entries of the folder tree. The
+// returned error then wraps ctx.Err().
+func DirSourceContext(ctx context.Context, root string) (*Dir, error) {
+	d := &Dir{root: root}
+	if _, err := d.refresh(ctx); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// Refresh lists the files under the root folder again.
+func (d *Dir) Refresh() error {
+	_, err := d.refresh(context.Background())
+	return err
+}
+
+// refresh lists the files and reports whether any of them was added, removed
+// or modified since the last listing.
+func (d *Dir) refresh(ctx context.Context) (changed bool, err error) {
+	info, err := os.Lstat(d.root)
+	if err != nil {
+		return false, errors.New("blob.DirSource: " + err.Error())
+	}
+	if !info.IsDir() {
+		return false, errors.New("blob.DirSource: " + d.root + " is not a folder")
+	}
+
+	var files []dirFile
+	err = filepath.WalkDir(d.root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		relPath, _ := filepath.Rel(d.root, path)
+		files = append(files, dirFile{
+			id:      filepath.ToSlash(relPath),
+			size:    info.Size(),
+			modTime: info.ModTime(),
+		})
+		return nil
+	})
+	if ctx.Err() != nil {
+		return false, fmt.Errorf("blob.DirSource: %w", ctx.Err())
+	}
+	if err != nil {
+		return false, errors.New("blob.DirSource: " + err.Error())
+	}
+
+	index := make(map[string]int, len(files))
+	for i := range files {
+		index[files[i].id] = i
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	changed = len(files) != len(d.files)
+	for i := 0; i < len(files) && !changed; i++ {
+		changed = files[i].id != d.files[i].id ||
+			files[i].size != d.files[i].size ||
+			!files[i].modTime.Equal(d.files[i].modTime)
+	}
+	d.files = files
+	d.index = index
+	return changed, nil
+}
+
+// Watch refreshes the listing every interval until stop is called. If the
+// listing changed, or any file was modified, onChange is called, unless it is
+// nil. Errors while listing are ignored, the last listing is kept until the
+// folder can be listed again.
+func (d *Dir) Watch(interval time.Duration, onChange func()) (stop func()) {
+	done := make(chan struct{})
+	var once sync.Once
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if changed, err := d.refresh(context.Background()); err == nil && changed && onChange != nil {
+					onChange()
+				}
+			}
+		}
+	}()
+	return func() {
+		once.Do(func() { close(done) })
+	}
+}
+
+// ItemCount returns the number of files in the last listing.
+func (d *Dir) ItemCount() int {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return len(d.files)
+}
+
+// GetIDAtIndex returns the ID of the file at index i in the last listing or the
+// empty string if the given index is out of bounds.
+func (d *Dir) GetIDAtIndex(i int) string {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if i < 0 || i >= len(d.files) {
+		return ""
+	}
+	return d.files[i].id
+}
+
+// Open opens the file with the given ID. If the ID is not in the last listing
+// or the file was removed since, the error wraps ErrNotFound.
+func (d *Dir) Open(id string) (ItemReader, error) {
+	path, err := d.path("blob.Dir.Open", id)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, d.fileError("blob.Dir.Open", id, err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, itemError("blob.Dir.Open", id, err)
+	}
+	return &fileItem{File: f, size: info.Size(), modTime: info.ModTime()}, nil
+}
+
+// ReadAll reads the whole file with the given ID. If the ID is not in the last
+// listing or the file was removed since, the error wraps ErrNotFound.
+func (d *Dir) ReadAll(id string) ([]byte, error) {
+	path, err := d.path("blob.Dir.ReadAll", id)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, d.fileError("blob.Dir.ReadAll", id, err)
+	}
+	return data, nil
+}
+
+func (d *Dir) path(context, id string) (string, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if _, ok := d.index[id]; !ok {
+		return "", notFoundError(context, id)
+	}
+	return filepath.Join(d.root, filepath.FromSlash(id)), nil
+}
+
+func (d *Dir) fileError(context, id string, err error) error {
+	if errors.Is(err, fs.ErrNotExist) {
+		return notFoundError(context, id)
+	}
+	return itemError(context, id, err)
+}
+
+// fileItem is the ItemReader for a file of a Dir.
+type fileItem struct {
+	*os.File
+	size    int64
+	modTime time.Time
+}
+
+func (f *fileItem) Size() int64 {
+	return f.size
+}
+
+// version changes when the file is modified, so Handler does not serve
+// outdated ETags.
+func (d *Dir) version(id string, r ItemReader) string {
+	f := r.(*fileItem)
+	return strconv.FormatInt(f.size, 10) + ":" + strconv.FormatInt(f.modTime.UnixNano(), 10)
+}
@@ -0,0 +1,157 @@
+package blob_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gonutz/blob"
+)
+
+func writeTestFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDirSourceUsesRelativeSlashPathsAsIDs(t *testing.T) {
+	root := t.TempDir()
+	writeTestFile(t, filepath.Join(root, "index.html"), "<html>")
+	writeTestFile(t, filepath.Join(root, "static", "logo.png"), "png")
+	writeTestFile(t, filepath.Join(root, "static", "css", "main.css"), "css")
+
+	d, err := blob.DirSource(root)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for i := 0; i < d.ItemCount(); i++ {
+		ids = append(ids, d.GetIDAtIndex(i))
+	}
+	checkStrings(t, ids, []string{"index.html", "static/css/main.css", "static/logo.png"})
+
+	data, err := d.ReadAll("static/logo.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkBytes(t, data, []byte("png"))
+
+	r, err := d.Open("static/css/main.css")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if r.Size() != 3 {
+		t.Error("want size 3 but have", r.Size())
+	}
+	data, _ = io.ReadAll(r)
+	checkBytes(t, data, []byte("css"))
+}
+
+func TestDirSourceRefresh(t *testing.T) {
+	root := t.TempDir()
+	writeTestFile(t, filepath.Join(root, "a"), "a")
+	d, err := blob.DirSource(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writeTestFile(t, filepath.Join(root, "b"), "b")
+	if _, err := d.Open("b"); !errors.Is(err, blob.ErrNotFound) {
+		t.Error("new file must not be visible before Refresh but have", err)
+	}
+	os.Remove(filepath.Join(root, "a"))
+	if _, err := d.ReadAll("a"); !errors.Is(err, blob.ErrNotFound) {
+		t.Error("removed file must not be found but have", err)
+	}
+
+	if err := d.Refresh(); err != nil {
+		t.Fatal(err)
+	}
+	if d.ItemCount() != 1 || d.GetIDAtIndex(0) != "b" {
+		t.Error("want only b after Refresh but have", d.ItemCount(), d.GetIDAtIndex(0))
+	}
+}
+
+func TestDirSourceWatchReportsChanges(t *testing.T) {
+	root := t.TempDir()
+	d, err := blob.DirSource(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	changed := make(chan bool, 1)
+	stop := d.Watch(time.Millisecond, func() {
+		select {
+		case changed <- true:
+		default:
+		}
+	})
+	defer stop()
+
+	writeTestFile(t, filepath.Join(root, "new"), "new")
+
+	select {
+	case <-changed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no change reported")
+	}
+	if _, err := d.ReadAll("new"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestDirSourceFailsForFiles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "file")
+	writeTestFile(t, path, "")
+	if _, err := blob.DirSource(path); err == nil {
+		t.Error("error expected")
+	}
+}
+
+func TestHandlerServesChangedDirFiles(t *testing.T) {
+	root := t.TempDir()
+	path := filepath.Join(root, "a.txt")
+	writeTestFile(t, path, "old")
+	d, err := blob.DirSource(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := blob.Handler(d)
+	oldETag := serve(h, "GET", "/a.txt").Header().Get("ETag")
+
+	// same size, so only the modification time tells the change
+	writeTestFile(t, path, "new")
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+
+	w := serve(h, "GET", "/a.txt", "If-None-Match", oldETag)
+	if w.Code != http.StatusOK || w.Body.String() != "new" {
+		t.Errorf("want the new file but have %d %q", w.Code, w.Body.String())
+	}
+	if etag := w.Header().Get("ETag"); etag == oldETag {
+		t.Error("the ETag did not change with the file")
+	}
+}
+
+func TestDirSourceContextStopsWhenCanceled(t *testing.T) {
+	root := t.TempDir()
+	writeTestFile(t, filepath.Join(root, "a.txt"), "a")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := blob.DirSourceContext(ctx, root); !errors.Is(err, context.Canceled) {
+		t.Error("want context.Canceled but have", err)
+	}
+}
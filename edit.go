@@ -0,0 +1,655 @@
+package blob
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+	"os"
+	"sort"
+)
+
+// trailingDataStart is the offset of the first data byte in a blob with a
+// trailing index, after the flags and the index offset.
+const trailingDataStart = 12
+
+// Editor changes a blob file in place, see Edit. It embeds a BlobReader for
+// reading the current items, which always reflects the last change.
+//
+// An Editor is not safe for concurrent use. Item readers that were created
+// before a change must not be used after it, their data may be overwritten.
+type Editor struct {
+	*BlobReader
+	path string
+	file *os.File
+	// free holds the regions of the file that are not used anymore.
+	free []region
+	// tail is the end of the committed blob, data that does not fit into
+	// the free space is written there.
+	tail uint64
+	// indexSize is the size of the committed index, it starts at
+	// indexOffset. It is 0 as long as the file has its header at the start.
+	indexSize uint64
+	// dataStart is the end of the header at the start of the file, the
+	// header becomes free space with the first change. Until then the items'
+	// offsets are counted from dataStart.
+	dataStart uint64
+}
+
+// region is a part of a file.
+type region struct {
+	offset uint64
+	size   uint64
+}
+
+// Edit opens the blob file at the given path for changing it in place. Items
+// are added or replaced with Put and removed with Delete, without rewriting
+// the existing data.
+//
+// Replaced and deleted items leave free space behind, see FreeBytes. New data
+// and headers are written into the first free region that is large enough, or
+// else to the end of the file. Each change is committed by writing a new
+// header into space that the current one does not use, and only then switching
+// the offset at the start of the file over to it. If the program crashes during
+// a change, the file keeps its previous content. Compact rewrites the file
+// without any free space.
+//
+// A file that was changed this way stores its header at the end. It can be
+// read with Read, Open, OpenPath and FromBytes as usual, but older versions of
+// this package and Scanner can not read it. Compact turns it back into a blob
+// with the header at the start.
+//
+// Call Close when done editing.
+func Edit(path string) (*Editor, error) {
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return nil, errors.New("edit blob: " + err.Error())
+	}
+	e, err := newEditor(path, f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return e, nil
+}
+
+func newEditor(path string, f *os.File) (*Editor, error) {
+	r := bufio.NewReader(f)
+	h, dataLength, err := readHeader(r, ReadOptions{})
+	if err != nil {
+		return nil, err
+	}
+	size, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, errors.New("edit blob: " + err.Error())
+	}
+
+	e := &Editor{path: path, file: f}
+	if h.indexOffset == 0 {
+		dataStart := 4 + h.length
+		if dataLength > uint64(size)-dataStart {
+			return nil, errors.New("edit blob: data extends beyond the end of the file")
+		}
+		e.dataStart = dataStart
+		e.tail = max(dataStart+dataLength, trailingDataStart)
+	} else {
+		if _, err := f.Seek(int64(h.indexOffset), io.SeekStart); err != nil {
+			return nil, errors.New("edit blob: " + err.Error())
+		}
+		var end uint64
+		h, e.free, end, err = readTrailingIndex(bufio.NewReader(f), h.indexOffset, ReadOptions{})
+		if err != nil {
+			return nil, err
+		}
+		if end > uint64(size) {
+			return nil, errors.New("edit blob: data extends beyond the end of the file")
+		}
+		e.tail = end
+		e.indexSize = h.length
+	}
+	e.BlobReader = &BlobReader{header: h, r: f, zero: int64(e.dataStart), at: f, closer: f}
+	return e, nil
+}
+
+// FreeBytes returns the number of bytes in the file that are not used by any
+// item or the header. Compact reclaims them.
+func (e *Editor) FreeBytes() int64 {
+	var n uint64
+	for _, r := range e.free {
+		n += r.size
+	}
+	return int64(n)
+}
+
+// Put stores data under the given ID. If there is an item with that ID
+// already, the first one is replaced, keeping its index. Otherwise a new item
+// is added at the end.
+func (e *Editor) Put(id string, data []byte) error {
+	if e.closed.Load() {
+		return ErrClosed
+	}
+	if len(id) > MaxIDLen {
+		return errors.New("blob.Editor.Put: ID is too long")
+	}
+	items := e.absoluteItems()
+	offset := e.allocate(items, uint64(len(data)))
+	if _, err := e.file.WriteAt(data, int64(offset)); err != nil {
+		return itemError("blob.Editor.Put", id, err)
+	}
+
+	item := indexItem{id: id, start: offset, end: offset + uint64(len(data))}
+	if i, found := e.find(id); found {
+		items[i] = item
+	} else {
+		items = append(items, item)
+	}
+	return e.commit(items)
+}
+
+// Delete removes all items with the given ID. If there is no such item, the
+// error wraps ErrNotFound.
+func (e *Editor) Delete(id string) error {
+	if e.closed.Load() {
+		return ErrClosed
+	}
+	if _, found := e.find(id); !found {
+		return notFoundError("blob.Editor.Delete", id)
+	}
+	var items []indexItem
+	for _, item := range e.absoluteItems() {
+		if item.id != id {
+			items = append(items, item)
+		}
+	}
+	return e.commit(items)
+}
+
+// absoluteItems returns a copy of the items with their offsets counted from
+// the start of the file.
+func (e *Editor) absoluteItems() []indexItem {
+	items := append([]indexItem{}, e.items...)
+	for i := range items {
+		items[i].start += uint64(e.zero)
+		items[i].end += uint64(e.zero)
+	}
+	return items
+}
+
+// committed returns the regions of the file that the committed header refers
+// to, including the header itself. They must not be overwritten before the
+// next commit. items are the committed items with absolute offsets.
+func (e *Editor) committed(items []indexItem) []region {
+	used := itemRegions(items)
+	if e.indexOffset != 0 {
+		used = append(used, region{0, trailingDataStart}, region{e.indexOffset, e.indexSize})
+	} else {
+		used = append(used, region{0, max(e.dataStart, trailingDataStart)})
+	}
+	return used
+}
+
+// allocate returns the offset at which size bytes can be written without
+// overwriting anything that is committed. It uses the first free region that
+// is large enough or the end of the file. items are the committed items with
+// absolute offsets.
+func (e *Editor) allocate(items []indexItem, size uint64) uint64 {
+	for _, r := range gaps(e.committed(items), e.tail) {
+		if r.size >= size {
+			return r.offset
+		}
+	}
+	return e.tail
+}
+
+// commit writes a new index for the given items and makes it the current one.
+// The index is written into free space that neither the committed header nor
+// the new items use, or at the end of the file.
+func (e *Editor) commit(items []indexItem) error {
+	used := itemRegions(items)
+	safe := append(e.committed(e.absoluteItems()), used...)
+	end := e.tail
+	for _, r := range used {
+		end = max(end, r.offset+r.size)
+	}
+
+	var index []byte
+	var offset uint64
+	var free []region
+	var err error
+	for _, r := range gaps(safe, end) {
+		// the index goes to the end of the free region, so the rest of it
+		// stays in one piece
+		index, offset, free, err = placeIndex(items, used, r.size-1, func(size uint64) uint64 {
+			return r.offset + r.size - size
+		})
+		if index != nil || err != nil {
+			break
+		}
+	}
+	if index == nil && err == nil {
+		index, offset, free, err = placeIndex(items, used, math.MaxUint64, func(uint64) uint64 {
+			return end
+		})
+	}
+	if err != nil {
+		return errors.New("blob.Editor: " + err.Error())
+	}
+
+	if _, err := e.file.WriteAt(index, int64(offset)); err != nil {
+		return errors.New("blob.Editor: write index: " + err.Error())
+	}
+	// the data and index must be on disk before the file refers to them
+	if err := e.file.Sync(); err != nil {
+		return errors.New("blob.Editor: " + err.Error())
+	}
+	var start [trailingDataStart]byte
+	byteOrder.PutUint32(start[:], headerFlagTrailing)
+	byteOrder.PutUint64(start[4:], offset)
+	if _, err := e.file.WriteAt(start[:], 0); err != nil {
+		return errors.New("blob.Editor: write index offset: " + err.Error())
+	}
+	if err := e.file.Sync(); err != nil {
+		return errors.New("blob.Editor: " + err.Error())
+	}
+
+	e.header = header{
+		items:       items,
+		sorted:      isSorted(items),
+		length:      uint64(len(index)),
+		indexOffset: offset,
+	}
+	e.zero = 0
+	e.free = free
+	e.indexSize = uint64(len(index))
+	e.tail = blobEnd(items, offset+e.indexSize)
+	// anything after the blob is unused, not being able to cut it off only
+	// wastes space
+	e.file.Truncate(int64(e.tail))
+	return nil
+}
+
+// placeIndex encodes the index for the given items. place returns the offset
+// of the index for a given index size. The free regions stored in the index
+// are the gaps between the used regions and the index itself. It returns a
+// nil index if the index would be larger than maxSize.
+func placeIndex(items []indexItem, used []region, maxSize uint64, place func(size uint64) uint64) (index []byte, offset uint64, free []region, err error) {
+	// the index size depends on the number of free regions, which in turn
+	// may depend on where the index is, so try until the size is stable
+	size := uint64(0)
+	for range 3 {
+		if size > maxSize {
+			return nil, 0, nil, nil
+		}
+		offset = place(size)
+		all := append(append([]region{}, used...), region{offset, size})
+		free = gaps(all, blobEnd(items, offset+size))
+		index, err = encodeTrailingIndex(items, free)
+		if err != nil {
+			return nil, 0, nil, err
+		}
+		if uint64(len(index)) == size {
+			return index, offset, free, nil
+		}
+		size = uint64(len(index))
+	}
+	return nil, 0, nil, nil
+}
+
+// blobEnd returns the end of the last item or end, whichever is larger.
+func blobEnd(items []indexItem, end uint64) uint64 {
+	for _, item := range items {
+		end = max(end, item.end)
+	}
+	return end
+}
+
+// itemRegions returns the regions of the items' data.
+func itemRegions(items []indexItem) []region {
+	used := make([]region, 0, len(items))
+	for _, item := range items {
+		used = append(used, region{item.start, item.end - item.start})
+	}
+	return used
+}
+
+// gaps returns the parts of the file from trailingDataStart to end that are not
+// covered by any of the used regions, in order.
+func gaps(used []region, end uint64) []region {
+	sorted := append([]region{}, used...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].offset < sorted[j].offset
+	})
+	var free []region
+	pos := uint64(trailingDataStart)
+	for _, r := range sorted {
+		if r.size == 0 {
+			continue
+		}
+		if r.offset > pos && pos < end {
+			free = append(free, region{pos, min(r.offset, end) - pos})
+		}
+		pos = max(pos, r.offset+r.size)
+	}
+	if pos < end {
+		free = append(free, region{pos, end - pos})
+	}
+	return free
+}
+
+// Compact rewrites the file without any free space, with the header at the
+// start. The file is replaced atomically, see AtomicWriteFile. Item readers
+// created before Compact can not be used anymore. If the file can not be
+// opened again afterwards, the Editor is closed.
+func (e *Editor) Compact() error {
+	if e.closed.Load() {
+		return ErrClosed
+	}
+	items := make([]indexItem, len(e.items))
+	var offset uint64
+	for i, item := range e.items {
+		size := item.end - item.start
+		items[i] = indexItem{id: item.id, start: offset, end: offset + size}
+		offset += size
+	}
+	closed := false
+	err := AtomicWriteFile(e.path, false, func(w io.Writer) error {
+		headerData, err := encodeHeader(items, false)
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(headerData); err != nil {
+			return err
+		}
+		for i := range e.items {
+			if err := e.copyItem(w, i); err != nil {
+				return itemError("copy", e.items[i].id, err)
+			}
+		}
+		// the new file is renamed over this one next, which fails on Windows
+		// as long as it is open
+		closed = true
+		return e.BlobReader.Close()
+	})
+	if err != nil && !closed {
+		return errors.New("blob.Editor.Compact: " + err.Error())
+	}
+
+	// continue with the new file, or with the old one if it was not replaced
+	f, openErr := os.OpenFile(e.path, os.O_RDWR, 0)
+	if openErr != nil {
+		return errors.New("blob.Editor.Compact: " + openErr.Error())
+	}
+	reopened, openErr := newEditor(e.path, f)
+	if openErr != nil {
+		f.Close()
+		return errors.New("blob.Editor.Compact: " + openErr.Error())
+	}
+	*e = *reopened
+	if err != nil {
+		return errors.New("blob.Editor.Compact: " + err.Error())
+	}
+	return nil
+}
+
+func isSorted(items []indexItem) bool {
+	return sort.SliceIsSorted(items, func(i, j int) bool {
+		return items[i].id < items[j].id
+	})
+}
+
+// encodeTrailingIndex returns the header of a blob with a trailing index. The
+// format is as follows, all numbers are encoded in little endian byte order:
+//
+//	uint32: number of items
+//	uint32: number of free regions
+//	loop, once per item {
+//	  uint16: ID length in bytes, length of the following ID
+//	  string: ID, UTF-8 encoded
+//	  uint64: data offset, from the start of the blob
+//	  uint64: data length in bytes
+//	}
+//	loop, once per free region {
+//	  uint64: offset, from the start of the blob
+//	  uint64: length in bytes
+//	}
+//	uint32: CRC-32 (IEEE) of all of the above
+func encodeTrailingIndex(items []indexItem, free []region) ([]byte, error) {
+	if len(items) > math.MaxUint32 || len(free) > math.MaxUint32 {
+		return nil, errors.New("too many items")
+	}
+	var buf []byte
+	buf = byteOrder.AppendUint32(buf, uint32(len(items)))
+	buf = byteOrder.AppendUint32(buf, uint32(len(free)))
+	for _, item := range items {
+		if len(item.id) > MaxIDLen {
+			return nil, errors.New("ID is too long")
+		}
+		buf = byteOrder.AppendUint16(buf, uint16(len(item.id)))
+		buf = append(buf, item.id...)
+		buf = byteOrder.AppendUint64(buf, item.start)
+		buf = byteOrder.AppendUint64(buf, item.end-item.start)
+	}
+	for _, r := range free {
+		buf = byteOrder.AppendUint64(buf, r.offset)
+		buf = byteOrder.AppendUint64(buf, r.size)
+	}
+	return byteOrder.AppendUint32(buf, crc32.ChecksumIEEE(buf)), nil
+}
+
+// readTrailingIndex reads the header of a blob with a trailing index from r,
+// which must be at the index offset. It returns the header, the free regions
+// and the end of the blob, which is the end of the header or of the last item,
+// whichever comes later. It reads in small pieces and stops right after the
+// header, callers reading from a file should buffer r.
+func readTrailingIndex(r io.Reader, indexOffset uint64, opts ReadOptions) (header, []region, uint64, error) {
+	fail := func(msg string) (header, []region, uint64, error) {
+		return header{}, nil, 0, errors.New("read blob index: " + msg)
+	}
+
+	crc := crc32.NewIEEE()
+	in := io.TeeReader(r, crc)
+	var size uint64
+	read := func(data any) error {
+		size += uint64(binary.Size(data))
+		if opts.MaxHeaderSize > 0 && size > uint64(opts.MaxHeaderSize) {
+			return errors.New("header exceeds maximum size")
+		}
+		return binary.Read(in, byteOrder, data)
+	}
+
+	var counts [2]uint32
+	if err := read(&counts); err != nil {
+		return fail(err.Error())
+	}
+	if opts.MaxItemCount > 0 && int64(counts[0]) > int64(opts.MaxItemCount) {
+		return fail("too many items")
+	}
+
+	h := header{indexOffset: indexOffset}
+	var dataLength uint64
+	for range counts[0] {
+		var idLength uint16
+		if err := read(&idLength); err != nil {
+			return fail(err.Error())
+		}
+		id := make([]byte, idLength)
+		if err := read(id); err != nil {
+			return fail(err.Error())
+		}
+		var offsetAndSize [2]uint64
+		if err := read(&offsetAndSize); err != nil {
+			return fail(err.Error())
+		}
+		start, end := offsetAndSize[0], offsetAndSize[0]+offsetAndSize[1]
+		if start < trailingDataStart || end < start || end > math.MaxInt64 {
+			return fail("item data lies outside the blob")
+		}
+		dataLength += end - start
+		if opts.MaxDataSize > 0 && dataLength > uint64(opts.MaxDataSize) {
+			return fail("data exceeds maximum size")
+		}
+		h.items = append(h.items, indexItem{id: string(id), start: start, end: end})
+	}
+	var free []region
+	for range counts[1] {
+		var offsetAndSize [2]uint64
+		if err := read(&offsetAndSize); err != nil {
+			return fail(err.Error())
+		}
+		free = append(free, region{offsetAndSize[0], offsetAndSize[1]})
+	}
+
+	want := crc.Sum32()
+	var checksum uint32
+	if err := binary.Read(in, byteOrder, &checksum); err != nil {
+		return fail(err.Error())
+	}
+	if checksum != want {
+		return fail("checksum mismatch")
+	}
+	size += 4
+	h.length = size
+	h.sorted = isSorted(h.items)
+	return h, free, blobEnd(h.items, indexOffset+size), nil
+}
+
+// readTrailing reads the rest of a blob with a trailing index from r, which
+// must be right after the index offset. The data is stored in index order in
+// the returned blob, so it can be written as a regular blob.
+//
+// If r is an io.Seeker, the index is read first, so that progress can be
+// reported and cancellation can name the items from the start. Otherwise this
+// is only possible for the data after the index.
+func readTrailing(ctx context.Context, r io.Reader, indexOffset uint64, opts ReadOptions) (*Blob, error) {
+	// the data may contain free space, compacting the blob helps with the
+	// limit
+	exceeds := func(n uint64) bool {
+		return opts.MaxDataSize > 0 && n > uint64(opts.MaxDataSize)
+	}
+	fail := func(err error) (*Blob, error) {
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("read blob data: %w", ctx.Err())
+		}
+		return nil, errors.New("read blob data: " + err.Error())
+	}
+
+	// data holds the blob from trailingDataStart on, as far as it was read
+	var data []byte
+	h, end, err := peekTrailingIndex(ctx, r, indexOffset, opts)
+	if err == errUnseekable {
+		before := indexOffset - trailingDataStart
+		if exceeds(before) {
+			return nil, errors.New("read blob data: data exceeds maximum size")
+		}
+		data, err = readBytes(&contextReader{ctx, r}, before)
+		if err != nil {
+			return fail(err)
+		}
+		// keep the header bytes, items may follow it
+		var index bytes.Buffer
+		h, _, end, err = readTrailingIndex(io.TeeReader(&contextReader{ctx, r}, &index), indexOffset, opts)
+		data = append(data, index.Bytes()...)
+	}
+	if ctx.Err() != nil {
+		return fail(ctx.Err())
+	}
+	if err != nil {
+		return nil, err
+	}
+	if exceeds(end - trailingDataStart - h.length) {
+		return nil, errors.New("read blob data: data exceeds maximum size")
+	}
+
+	parts, gaps := trailingLayout(h, end)
+	if t := newTracker(ctx, opts.Progress, trailingDataStart, parts); t != nil {
+		t.gaps = gaps
+		t.add(trailingDataStart + len(data))
+		r = &trackingReader{r, t}
+	} else {
+		r = &contextReader{ctx, r}
+	}
+	rest, err := readBytes(r, end-trailingDataStart-uint64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("read blob data: %w", err)
+	}
+	data = append(data, rest...)
+
+	return fromTrailing(h, data, trailingDataStart), nil
+}
+
+// errUnseekable is returned by peekTrailingIndex if r can not seek.
+var errUnseekable = errors.New("reader can not seek")
+
+// peekTrailingIndex reads the index of a blob with a trailing index from r,
+// which must be right after the index offset, and then seeks back there. It
+// returns the header and the end of the blob, see readTrailingIndex.
+func peekTrailingIndex(ctx context.Context, r io.Reader, indexOffset uint64, opts ReadOptions) (header, uint64, error) {
+	s, ok := r.(io.Seeker)
+	if !ok {
+		return header{}, 0, errUnseekable
+	}
+	// the blob does not necessarily start at the start of r
+	pos, err := s.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return header{}, 0, errUnseekable
+	}
+	if _, err := s.Seek(pos-trailingDataStart+int64(indexOffset), io.SeekStart); err != nil {
+		return header{}, 0, errors.New("read blob index: " + err.Error())
+	}
+	h, _, end, err := readTrailingIndex(bufio.NewReader(&contextReader{ctx, r}), indexOffset, opts)
+	if err != nil {
+		return header{}, 0, err
+	}
+	if _, err := s.Seek(pos, io.SeekStart); err != nil {
+		return header{}, 0, errors.New("read blob index: " + err.Error())
+	}
+	return h, end, nil
+}
+
+// trailingLayout returns the parts of a blob with a trailing index from
+// trailingDataStart to end, in file order. These are the items and, in
+// between, gaps of free space and the index, which are marked in gaps.
+func trailingLayout(h header, end uint64) (parts []indexItem, gaps []bool) {
+	items := append([]indexItem(nil), h.items...)
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].start < items[j].start
+	})
+	pos := uint64(trailingDataStart)
+	for _, item := range items {
+		if item.start > pos {
+			parts = append(parts, indexItem{start: pos, end: item.start})
+			gaps = append(gaps, true)
+		}
+		// items do not overlap, but the parts must not either
+		item.start = max(item.start, pos)
+		item.end = max(item.end, pos)
+		parts = append(parts, item)
+		gaps = append(gaps, false)
+		pos = item.end
+	}
+	if pos < end {
+		parts = append(parts, indexItem{start: pos, end: end})
+		gaps = append(gaps, true)
+	}
+	return parts, gaps
+}
+
+// fromTrailing copies the items of a blob with a trailing index into a new
+// Blob. data holds the blob starting at offset base.
+func fromTrailing(h header, data []byte, base uint64) *Blob {
+	b := New()
+	var size uint64
+	for _, item := range h.items {
+		size += item.end - item.start
+	}
+	b.data = make([]byte, 0, size)
+	for _, item := range h.items {
+		b.Append(item.id, data[item.start-base:item.end-base])
+	}
+	return b
+}
@@ -0,0 +1,366 @@
+package blob_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/gonutz/blob"
+)
+
+// readBlobFile reads the blob file at path in every supported way and checks
+// that they all agree.
+func readBlobFile(t *testing.T, path string) *blob.Blob {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	read, err := blob.Read(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fromBytes, err := blob.FromBytes(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opened, err := blob.OpenPath(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer opened.Close()
+	index, err := blob.ReadIndex(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// without seeking, the header at the end is read after the data
+	streamed, err := blob.Read(struct{ io.Reader }{bytes.NewReader(data)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if fromBytes.ItemCount() != read.ItemCount() ||
+		opened.ItemCount() != read.ItemCount() ||
+		index.ItemCount() != read.ItemCount() ||
+		streamed.ItemCount() != read.ItemCount() {
+		t.Fatal("item counts differ")
+	}
+	for i, item := range index.Items() {
+		want, _ := read.GetByIndex(i)
+		got, _ := fromBytes.GetByIndex(i)
+		checkBytes(t, got, want)
+		got, _ = streamed.GetByIndex(i)
+		checkBytes(t, got, want)
+		r, _ := opened.GetByIndex(i)
+		got, _ = io.ReadAll(r)
+		checkBytes(t, got, want)
+		checkBytes(t, data[item.Offset:item.Offset+item.Size], want)
+	}
+	return read
+}
+
+func TestEditPutAndDelete(t *testing.T) {
+	original := blob.New()
+	original.Append("a", []byte("first"))
+	original.Append("b", []byte("second"))
+	path := writeBlobFile(t, original)
+	e, err := blob.Edit(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer e.Close()
+
+	if err := e.Put("b", []byte("replaced")); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Put("c", []byte("new")); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Delete("a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Delete("a"); !errors.Is(err, blob.ErrNotFound) {
+		t.Error("want ErrNotFound for a missing item but have", err)
+	}
+
+	if s, err := e.GetString("b"); s != "replaced" || err != nil {
+		t.Errorf("the editor should read the new data but has %q, %v", s, err)
+	}
+	checkBlobContent(t, readBlobFile(t, path), "b", "replaced", "c", "new")
+	if e.FreeBytes() == 0 {
+		t.Error("replaced and deleted data should be free")
+	}
+
+	// edit the file again after re-opening it
+	e.Close()
+	e, err = blob.Edit(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Put("a", []byte("back")); err != nil {
+		t.Fatal(err)
+	}
+	checkBlobContent(t, readBlobFile(t, path), "b", "replaced", "c", "new", "a", "back")
+}
+
+func TestEditorItemOffsetsPointIntoTheFile(t *testing.T) {
+	original := blob.New()
+	original.Append("a", []byte("first"))
+	original.Append("b", []byte("second"))
+	path := writeBlobFile(t, original)
+	e, err := blob.Edit(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer e.Close()
+
+	check := func() {
+		t.Helper()
+		data, _ := os.ReadFile(path)
+		for _, item := range e.Items() {
+			want, _ := e.ReadAll(item.ID)
+			checkBytes(t, data[item.Offset:item.Offset+item.Size], want)
+		}
+	}
+	check()
+	e.Put("c", []byte("new"))
+	check()
+}
+
+func TestEditIgnoresUncommittedData(t *testing.T) {
+	original := blob.New()
+	original.Append("a", []byte("first"))
+	original.Append("b", []byte("second"))
+	path := writeBlobFile(t, original)
+	e, err := blob.Edit(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Put("c", []byte("new")); err != nil {
+		t.Fatal(err)
+	}
+	e.Close()
+
+	// a crash during the next change leaves data after the committed header
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write([]byte("data and half a header"))
+	f.Close()
+
+	checkBlobContent(t, readBlobFile(t, path), "a", "first", "b", "second", "c", "new")
+	e, err = blob.Edit(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer e.Close()
+	if err := e.Put("d", []byte("after crash")); err != nil {
+		t.Fatal(err)
+	}
+	checkBlobContent(t, readBlobFile(t, path),
+		"a", "first", "b", "second", "c", "new", "d", "after crash")
+}
+
+func TestCompactRemovesFreeSpace(t *testing.T) {
+	original := blob.New()
+	original.Append("a", []byte("first"))
+	original.Append("b", []byte("second"))
+	path := writeBlobFile(t, original)
+	e, err := blob.Edit(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer e.Close()
+	e.Put("a", []byte("replaced"))
+	e.Delete("b")
+	e.Put("c", []byte("new"))
+
+	if err := e.Compact(); err != nil {
+		t.Fatal(err)
+	}
+
+	if e.FreeBytes() != 0 {
+		t.Error("want no free space but have", e.FreeBytes())
+	}
+	want := blob.New()
+	want.Append("a", []byte("replaced"))
+	want.Append("c", []byte("new"))
+	wantData, _ := want.MarshalBinary()
+	data, _ := os.ReadFile(path)
+	checkBytes(t, data, wantData)
+
+	// the editor keeps working on the compacted file
+	if err := e.Put("d", []byte("more")); err != nil {
+		t.Fatal(err)
+	}
+	checkBlobContent(t, readBlobFile(t, path), "a", "replaced", "c", "new", "d", "more")
+}
+
+func TestScannerRejectsEditedBlob(t *testing.T) {
+	original := blob.New()
+	original.Append("a", []byte("first"))
+	original.Append("b", []byte("second"))
+	path := writeBlobFile(t, original)
+	e, err := blob.Edit(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.Put("c", []byte("new"))
+	e.Close()
+
+	f, _ := os.Open(path)
+	defer f.Close()
+	s := blob.NewScanner(f)
+	if s.Next() {
+		t.Fatal("the scanner can not read a header at the end")
+	}
+	if s.Err() == nil {
+		t.Error("error expected")
+	}
+}
+
+func TestEditReusesFreeSpace(t *testing.T) {
+	original := blob.New()
+	original.Append("a", []byte("first"))
+	original.Append("b", []byte("second"))
+	path := writeBlobFile(t, original)
+	e, err := blob.Edit(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer e.Close()
+
+	level := make([]byte, 1000)
+	var sizes []int64
+	for i := 0; i < 100; i++ {
+		level[0] = byte(i)
+		if err := e.Put("level", level); err != nil {
+			t.Fatal(err)
+		}
+		info, _ := os.Stat(path)
+		sizes = append(sizes, info.Size())
+	}
+
+	// the first saves need to build up free space, after that the file must
+	// not grow anymore
+	if sizes[len(sizes)-1] > sizes[9] {
+		t.Errorf("file grew from %d to %d bytes", sizes[9], sizes[len(sizes)-1])
+	}
+	if e.FreeBytes() > 2*int64(len(level)) {
+		t.Error("too much free space:", e.FreeBytes())
+	}
+	data, _ := e.ReadAll("level")
+	checkBytes(t, data, level)
+}
+
+func TestEditMatchesModel(t *testing.T) {
+	original := blob.New()
+	original.Append("a", []byte("first"))
+	original.Append("b", []byte("second"))
+	path := writeBlobFile(t, original)
+	model := map[string]string{"a": "first", "b": "second"}
+	order := []string{"a", "b"}
+	e, err := blob.Edit(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { e.Close() }()
+
+	rnd := rand.New(rand.NewPCG(1, 2))
+	ids := []string{"a", "b", "c", "d", "e"}
+	for step := 0; step < 300; step++ {
+		id := ids[rnd.IntN(len(ids))]
+		if _, ok := model[id]; ok && rnd.IntN(3) == 0 {
+			if err := e.Delete(id); err != nil {
+				t.Fatal(err)
+			}
+			delete(model, id)
+			order = slices.DeleteFunc(order, func(s string) bool { return s == id })
+		} else {
+			data := strings.Repeat(string(rune('A'+step%26)), rnd.IntN(300))
+			if err := e.Put(id, []byte(data)); err != nil {
+				t.Fatal(err)
+			}
+			if _, ok := model[id]; !ok {
+				order = append(order, id)
+			}
+			model[id] = data
+		}
+		if rnd.IntN(10) == 0 {
+			e.Close()
+			if e, err = blob.Edit(path); err != nil {
+				t.Fatal(step, err)
+			}
+		}
+
+		var want []string
+		for _, id := range order {
+			want = append(want, id, model[id])
+		}
+		checkBlobContent(t, readBlobFile(t, path), want...)
+		if t.Failed() {
+			t.Fatal("wrong content after step", step)
+		}
+	}
+}
+
+func TestReadEditedBlobReportsProgressAndNamesCanceledItem(t *testing.T) {
+	original := blob.New()
+	original.Append("a", []byte("first"))
+	original.Append("b", []byte("second"))
+	path := writeBlobFile(t, original)
+	e, err := blob.Edit(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.Put("large", bytes.Repeat([]byte{1}, 3<<20))
+	e.Delete("a")
+	e.Put("after", []byte("small"))
+	e.Close()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var calls []progressCall
+	_, err = blob.ReadWithProgress(bytes.NewReader(data), func(done, total int64, id string) {
+		calls = append(calls, progressCall{done, total, id})
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(calls) < 4 {
+		t.Error("want progress while reading the large item but have", calls)
+	}
+	var last int64
+	for _, c := range calls {
+		if c.total != int64(len(data)) || c.done <= last {
+			t.Fatal("wrong progress", calls)
+		}
+		last = c.done
+	}
+	if last != int64(len(data)) {
+		t.Error("want final done", len(data), "but have", last)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	_, err = blob.ReadContext(ctx, bytes.NewReader(data), blob.ReadOptions{
+		Progress: func(done, total int64, id string) {
+			if id == "large" {
+				cancel()
+			}
+		},
+	})
+	if !errors.Is(err, context.Canceled) || !strings.Contains(err.Error(), `"large"`) {
+		t.Error("want context.Canceled naming the item but have", err)
+	}
+}
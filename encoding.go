@@ -0,0 +1,86 @@
+package blob
+
+import (
+	"bytes"
+	"errors"
+	"io"
+)
+
+// Size returns the exact number of bytes that Write produces for the blob. It
+// does not check whether the blob can be written at all, e.g. if an ID is too
+// long, Size still returns a value but Write fails.
+func (b *Blob) Size() int64 {
+	return 4 + int64(b.length) + int64(b.dataSize())
+}
+
+// WriteTo writes the blob like Write and returns the number of bytes written.
+// It implements io.WriterTo.
+func (b *Blob) WriteTo(w io.Writer) (int64, error) {
+	cw := &countingWriter{w: w}
+	err := b.Write(cw)
+	return cw.n, err
+}
+
+// ReadFrom reads a blob from r like Read and replaces the contents of b with
+// it. It returns the number of bytes read. It implements io.ReaderFrom.
+//
+// Unlike other io.ReaderFrom implementations, ReadFrom does not read until
+// EOF. It stops right after the blob, so several blobs can be read from the
+// same stream. If an error occurs, b is left unchanged.
+func (b *Blob) ReadFrom(r io.Reader) (int64, error) {
+	cr := &countingReader{r: r}
+	read, err := Read(cr)
+	if err != nil {
+		return cr.n, err
+	}
+	*b = *read
+	return cr.n, nil
+}
+
+// MarshalBinary returns the blob as it would be written by Write. It
+// implements encoding.BinaryMarshaler.
+func (b *Blob) MarshalBinary() ([]byte, error) {
+	buf := bytes.NewBuffer(make([]byte, 0, b.Size()))
+	if err := b.Write(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBinary replaces the contents of b with the blob encoded in data.
+// It is an error if data contains more than one blob. The data is copied, the
+// caller may modify it afterwards. It implements encoding.BinaryUnmarshaler.
+func (b *Blob) UnmarshalBinary(data []byte) error {
+	r := bytes.NewReader(data)
+	read, err := Read(r)
+	if err != nil {
+		return err
+	}
+	if r.Len() != 0 {
+		return errors.New("blob.Blob.UnmarshalBinary: unexpected data after the blob")
+	}
+	*b = *read
+	return nil
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.n += int64(n)
+	return n, err
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.n += int64(n)
+	return n, err
+}
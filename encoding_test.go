@@ -0,0 +1,104 @@
+package blob_test
+
+import (
+	"bytes"
+	"encoding/gob"
+	"testing"
+
+	"github.com/gonutz/blob"
+)
+
+func TestSizePredictsWrittenLength(t *testing.T) {
+	b := blob.New()
+	if b.Size() != 4 {
+		t.Error("empty blob: want size 4 but have", b.Size())
+	}
+	b.Append("one", []byte("1"))
+	b.Append("two", []byte("22"))
+	b.Append("", nil)
+
+	var buf bytes.Buffer
+	n, err := b.WriteTo(&buf)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(buf.Len()) {
+		t.Error("WriteTo returned", n, "but wrote", buf.Len())
+	}
+	if b.Size() != n {
+		t.Error("Size returned", b.Size(), "but WriteTo wrote", n)
+	}
+}
+
+func TestReadFromStopsAfterBlob(t *testing.T) {
+	first := blob.New()
+	first.Append("a", []byte("A"))
+	second := blob.New()
+	second.Append("b", []byte("B"))
+	var buf bytes.Buffer
+	first.Write(&buf)
+	second.Write(&buf)
+	firstSize := first.Size()
+
+	var b blob.Blob
+	n, err := b.ReadFrom(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != firstSize {
+		t.Error("want", firstSize, "bytes read but have", n)
+	}
+	checkBlobContent(t, &b, "a", "A")
+
+	if _, err := b.ReadFrom(&buf); err != nil {
+		t.Fatal(err)
+	}
+	checkBlobContent(t, &b, "b", "B")
+}
+
+func TestBlobInGobMessage(t *testing.T) {
+	type message struct {
+		Name   string
+		Assets *blob.Blob
+	}
+	assets := blob.New()
+	assets.Append("logo", []byte{1, 2, 3})
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(message{Name: "x", Assets: assets}); err != nil {
+		t.Fatal(err)
+	}
+
+	var m message
+	if err := gob.NewDecoder(&buf).Decode(&m); err != nil {
+		t.Fatal(err)
+	}
+
+	if m.Name != "x" {
+		t.Error("wrong name", m.Name)
+	}
+	checkBlobContent(t, m.Assets, "logo", "\x01\x02\x03")
+}
+
+func TestUnmarshalBinaryCopiesDataAndRejectsTrailingBytes(t *testing.T) {
+	b := blob.New()
+	b.Append("id", []byte("data"))
+	data, err := b.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var c blob.Blob
+	if err := c.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	for i := range data {
+		data[i] = 0
+	}
+	checkBlobContent(t, &c, "id", "data")
+
+	data, _ = b.MarshalBinary()
+	if err := c.UnmarshalBinary(append(data, 0)); err == nil {
+		t.Error("error expected for trailing data")
+	}
+}
@@ -0,0 +1,162 @@
+package blob
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// ErrClosed is returned when reading from an item reader of a BlobReader that
+// was closed.
+var ErrClosed = errors.New("blob: reader is closed")
+
+// OpenPath opens the blob file at the given path like Open does. The returned
+// BlobReader owns the file, call Close when you are done with it.
+//
+// Unlike for Open, the item readers do not share a seek position. They use
+// positional reads on the file, so different item readers can be used in
+// parallel, e.g. from different Go routines. A single item reader must still
+// not be used concurrently.
+func OpenPath(path string) (*BlobReader, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.New("open blob: " + err.Error())
+	}
+	b, err := Open(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	b.at = f
+	b.closer = f
+	return b, nil
+}
+
+// Close makes all item readers fail with ErrClosed from now on. For a
+// BlobReader returned by OpenPath it also closes the file. A BlobReader
+// returned by Open does not own its io.ReadSeeker, closing the underlying data
+// is left to the caller. Close may be called more than once, later calls do
+// nothing.
+func (b *BlobReader) Close() error {
+	if b.closed.Swap(true) || b.closer == nil {
+		return nil
+	}
+	return b.closer.Close()
+}
+
+// ErrDirNotSynced is returned by AtomicWriteFile if the file was replaced but
+// the rename could not be synced to disk. The new file is in place, but a
+// crash may still bring back the old one.
+var ErrDirNotSynced = errors.New("blob: file replaced but folder not synced")
+
+// BackupSuffix is appended to the path of a file to get the path of its
+// backup, see WriteOptions.Backup.
+const BackupSuffix = ".bak"
+
+// WriteFile writes the blob to the file at the given path, like
+// WriteWithOptions. The file is replaced atomically, see AtomicWriteFile, so
+// a crash or a full disk never leaves a truncated blob behind. If
+// opts.Backup is set, the previous version of the file is kept.
+func (b *Blob) WriteFile(path string, opts WriteOptions) error {
+	return b.WriteFileContext(context.Background(), path, opts)
+}
+
+// WriteFileContext writes the file like WriteFile but stops when ctx is
+// canceled, see WriteContext. The file is left unchanged in that case.
+func (b *Blob) WriteFileContext(ctx context.Context, path string, opts WriteOptions) error {
+	return AtomicWriteFile(path, opts.Backup, func(w io.Writer) error {
+		return b.WriteContext(ctx, w, opts)
+	})
+}
+
+// AtomicWriteFile calls write to create the file at the given path. The data
+// is written to a temporary file in the same folder first, which is synced to
+// disk and then renamed to path. Readers thus either see the old or the new
+// file, never a partial one, even after a crash. If write fails, the temporary
+// file is removed and an existing file at path is left unchanged.
+//
+// If keepBackup is true and the file exists, its previous version is kept at
+// path+BackupSuffix, replacing an older backup.
+//
+// If syncing the folder fails after the file was replaced, the error wraps
+// ErrDirNotSynced.
+//
+// Use it for output that is streamed to an io.Writer, e.g. by Merge or Patch.
+func AtomicWriteFile(path string, keepBackup bool, write func(w io.Writer) error) (err error) {
+	mode := os.FileMode(0644)
+	info, statErr := os.Stat(path)
+	if statErr == nil {
+		mode = info.Mode().Perm()
+	}
+
+	dir := filepath.Dir(path)
+	f, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tmp*")
+	if err != nil {
+		return errors.New("blob.AtomicWriteFile: " + err.Error())
+	}
+	replaced := false
+	defer func() {
+		if err != nil && !replaced {
+			f.Close()
+			os.Remove(f.Name())
+		}
+	}()
+
+	if err := write(f); err != nil {
+		return err
+	}
+	if err := f.Chmod(mode); err != nil {
+		return errors.New("blob.AtomicWriteFile: " + err.Error())
+	}
+	if err := f.Sync(); err != nil {
+		return errors.New("blob.AtomicWriteFile: " + err.Error())
+	}
+	if err := f.Close(); err != nil {
+		return errors.New("blob.AtomicWriteFile: " + err.Error())
+	}
+
+	backup := path + BackupSuffix
+	movedToBackup := false
+	if keepBackup && statErr == nil {
+		// a hard link keeps the old file at path until the rename replaces it
+		os.Remove(backup)
+		if err := os.Link(path, backup); err != nil {
+			// not all file systems support links, renaming leaves a short
+			// moment without a file at path, but with the backup
+			if err := os.Rename(path, backup); err != nil {
+				return errors.New("blob.AtomicWriteFile: keep backup: " + err.Error())
+			}
+			movedToBackup = true
+		}
+	}
+	if err := os.Rename(f.Name(), path); err != nil {
+		if movedToBackup {
+			// do not leave the caller without a file at path
+			os.Rename(backup, path)
+		}
+		return errors.New("blob.AtomicWriteFile: " + err.Error())
+	}
+	replaced = true
+	if err := syncDir(dir); err != nil {
+		return fmt.Errorf("blob.AtomicWriteFile: %w: %v", ErrDirNotSynced, err)
+	}
+	return nil
+}
+
+// syncDir makes sure that a rename in the given folder is stored on disk.
+func syncDir(dir string) error {
+	if runtime.GOOS == "windows" {
+		// folders can not be opened for syncing on Windows
+		return nil
+	}
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
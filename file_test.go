@@ -0,0 +1,170 @@
+package blob_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/gonutz/blob"
+)
+
+func writeBlobFile(t *testing.T, b *blob.Blob) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := b.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "test.blob")
+	if err := os.WriteFile(path, buf.Bytes(), 0666); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestOpenPathReadsItemsInParallel(t *testing.T) {
+	b := blob.New()
+	for i := 0; i < 8; i++ {
+		b.Append(string(rune('a'+i)), bytes.Repeat([]byte{byte(i)}, 10000))
+	}
+	br, err := blob.OpenPath(writeBlobFile(t, b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer br.Close()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, br.ItemCount())
+	for i := 0; i < br.ItemCount(); i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			r, _ := br.GetByIndex(i)
+			var buf [7]byte
+			for {
+				n, err := r.Read(buf[:])
+				for _, c := range buf[:n] {
+					if c != byte(i) {
+						errs <- errors.New("wrong data")
+						return
+					}
+				}
+				if err == io.EOF {
+					return
+				}
+				if err != nil {
+					errs <- err
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+func TestReadingAfterCloseFails(t *testing.T) {
+	b := blob.New()
+	b.Append("id", []byte{1, 2, 3})
+	br, err := blob.OpenPath(writeBlobFile(t, b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, _ := br.GetByID("id")
+
+	if err := br.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := r.Read(make([]byte, 3)); err != blob.ErrClosed {
+		t.Error("want ErrClosed but got", err)
+	}
+	if err := br.Close(); err != nil {
+		t.Error("closing twice should do nothing but returned", err)
+	}
+}
+
+func TestOpenPathFailsForMissingOrBrokenFile(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := blob.OpenPath(filepath.Join(dir, "missing")); err == nil {
+		t.Error("error expected for missing file")
+	}
+	broken := filepath.Join(dir, "broken")
+	os.WriteFile(broken, []byte{1, 0, 0}, 0666)
+	if _, err := blob.OpenPath(broken); err == nil {
+		t.Error("error expected for broken file")
+	}
+}
+
+func TestWriteFileKeepsBackup(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.blob")
+	first := blob.New()
+	first.Append("version", []byte("1"))
+	second := blob.New()
+	second.Append("version", []byte("2"))
+
+	if err := first.WriteFile(path, blob.WriteOptions{Backup: true}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path + blob.BackupSuffix); !errors.Is(err, os.ErrNotExist) {
+		t.Error("there is nothing to back up for a new file but have", err)
+	}
+	if err := second.WriteFile(path, blob.WriteOptions{Backup: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	for p, want := range map[string]string{path: "2", path + blob.BackupSuffix: "1"} {
+		b, err := blob.OpenPath(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s, err := b.GetString("version"); s != want || err != nil {
+			t.Errorf("%s: want version %s but have %q, %v", p, want, s, err)
+		}
+		b.Close()
+	}
+}
+
+func TestFailedAtomicWriteLeavesFileUnchanged(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "out")
+	if err := os.WriteFile(path, []byte("old"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	err := blob.AtomicWriteFile(path, true, func(w io.Writer) error {
+		w.Write([]byte("partial"))
+		return errors.New("write failed")
+	})
+
+	if err == nil {
+		t.Fatal("error expected")
+	}
+	data, _ := os.ReadFile(path)
+	checkBytes(t, data, []byte("old"))
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Error("want only the original file but have", len(entries), "files")
+	}
+
+	err = blob.AtomicWriteFile(path, false, func(w io.Writer) error {
+		_, err := w.Write([]byte("new"))
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("want the file mode to be kept but have %v", info.Mode())
+	}
+}
@@ -1,3 +1,3 @@
 module github.com/gonutz/blob
 
-go 1.11
+go 1.23
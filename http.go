@@ -0,0 +1,165 @@
+package blob
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Handler returns an http.Handler that serves the items of s. The URL path,
+// without its leading slash, is the ID of the item to serve. A path ending in
+// a slash is treated as a directory and serves the item "index.html" in it. A
+// request for a directory without the trailing slash is redirected to the
+// path with the slash.
+//
+// The Content-Type is derived from the ID's file extension or, if there is
+// none, sniffed from the data. Each response carries a strong ETag computed
+// from the item's content. Conditional requests and Range requests are handled
+// by http.ServeContent.
+//
+// Requests may be served in parallel. For a BlobReader, and an Overlay with
+// BlobReader layers, the handler makes sure that the underlying io.ReadSeeker
+// is only ever used by one request at a time, see Open for why this is
+// necessary. A BlobReader returned by OpenPath does not have this restriction
+// and serves requests fully in parallel. Other Sources must allow their item
+// readers to be used in parallel.
+func Handler(s Source) http.Handler {
+	return &handler{source: s, etags: make(map[string]*etagEntry)}
+}
+
+type handler struct {
+	source Source
+	// readMu serializes all reads from the source if it requires it, see
+	// serialSource.
+	readMu sync.Mutex
+
+	etagMu sync.Mutex
+	etags  map[string]*etagEntry
+}
+
+// etagEntry is the ETag of an item. It is computed by the first request for
+// the item, other requests for it wait until done is closed. The version is
+// the one reported by a versionedSource.
+type etagEntry struct {
+	version string
+	done    chan struct{}
+	etag    string
+	err     error
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "405 method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	urlPath := r.URL.Path
+	if !strings.HasPrefix(urlPath, "/") {
+		urlPath = "/" + urlPath
+	}
+	id := strings.TrimPrefix(path.Clean(urlPath), "/")
+	if strings.HasSuffix(urlPath, "/") {
+		id = path.Join(id, "index.html")
+	}
+
+	item, err := h.source.Open(id)
+	if errors.Is(err, ErrNotFound) {
+		if index, err := h.source.Open(path.Join(id, "index.html")); err == nil && id != "" {
+			index.Close()
+			redirect := path.Base(urlPath) + "/"
+			if r.URL.RawQuery != "" {
+				redirect += "?" + r.URL.RawQuery
+			}
+			w.Header().Set("Location", redirect)
+			w.WriteHeader(http.StatusMovedPermanently)
+			return
+		}
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		http.Error(w, "500 internal server error", http.StatusInternalServerError)
+		return
+	}
+	defer item.Close()
+
+	var version string
+	if s, ok := h.source.(versionedSource); ok {
+		version = s.version(id, item)
+	}
+	content := h.reader(item)
+	etag, err := h.etag(id, version, content)
+	if err == nil {
+		_, err = content.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		http.Error(w, "500 internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("ETag", etag)
+	http.ServeContent(w, r, id, time.Time{}, content)
+}
+
+// reader returns r, wrapped so it holds readMu if reads must be serialized.
+func (h *handler) reader(r io.ReadSeeker) io.ReadSeeker {
+	if s, ok := h.source.(serialSource); ok && s.serialReads() {
+		return &lockedReadSeeker{mu: &h.readMu, r: r}
+	}
+	return r
+}
+
+// etag returns the strong ETag for the item with the given ID. It is computed
+// from the item's content, read from r, the first time it is requested and
+// again whenever its version changes. Only one request computes it, without
+// blocking requests for other items.
+func (h *handler) etag(id, version string, r io.Reader) (string, error) {
+	h.etagMu.Lock()
+	e, ok := h.etags[id]
+	if ok && e.version == version {
+		h.etagMu.Unlock()
+		<-e.done
+		return e.etag, e.err
+	}
+	e = &etagEntry{version: version, done: make(chan struct{})}
+	h.etags[id] = e
+	h.etagMu.Unlock()
+
+	hash := sha256.New()
+	if _, e.err = io.Copy(hash, r); e.err == nil {
+		e.etag = `"` + hex.EncodeToString(hash.Sum(nil)[:16]) + `"`
+	} else {
+		// let the next request try again
+		h.etagMu.Lock()
+		if h.etags[id] == e {
+			delete(h.etags, id)
+		}
+		h.etagMu.Unlock()
+	}
+	close(e.done)
+	return e.etag, e.err
+}
+
+// lockedReadSeeker holds mu during every Read and Seek on r.
+type lockedReadSeeker struct {
+	mu *sync.Mutex
+	r  io.ReadSeeker
+}
+
+func (r *lockedReadSeeker) Read(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.r.Read(p)
+}
+
+func (r *lockedReadSeeker) Seek(offset int64, whence int) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.r.Seek(offset, whence)
+}
@@ -0,0 +1,265 @@
+package blob_test
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gonutz/blob"
+)
+
+// handlersFor returns a Handler for every Source type over the items of b, by
+// name.
+func handlersFor(t *testing.T, b *blob.Blob) map[string]http.Handler {
+	return map[string]http.Handler{
+		"Blob":       blob.Handler(b),
+		"BlobReader": blob.Handler(openBlob(t, b)),
+		"Cache":      blob.Handler(blob.NewCache(openBlob(t, b), 1<<10)),
+	}
+}
+
+func serve(h http.Handler, method, url string, header ...string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, url, nil)
+	for i := 0; i+1 < len(header); i += 2 {
+		r.Header.Set(header[i], header[i+1])
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestHandlerServesItemsByPath(t *testing.T) {
+	b := blob.New()
+	b.Append("static/style.css", []byte("body{}"))
+	b.Append("data", []byte("0123456789"))
+
+	for name, h := range handlersFor(t, b) {
+		t.Run(name, func(t *testing.T) {
+			w := serve(h, "GET", "/static/style.css")
+			if w.Code != http.StatusOK {
+				t.Fatal("status", w.Code)
+			}
+			if w.Body.String() != "body{}" {
+				t.Error("body", w.Body.String())
+			}
+			if ct := w.Header().Get("Content-Type"); ct != "text/css; charset=utf-8" {
+				t.Error("content type", ct)
+			}
+
+			if w := serve(h, "GET", "/missing"); w.Code != http.StatusNotFound {
+				t.Error("want 404 but have", w.Code)
+			}
+			if w := serve(h, "POST", "/data"); w.Code != http.StatusMethodNotAllowed {
+				t.Error("want 405 but have", w.Code)
+			}
+		})
+	}
+}
+
+func TestHandlerServesIndexForDirectories(t *testing.T) {
+	b := blob.New()
+	b.Append("index.html", []byte("<html>root</html>"))
+	b.Append("static/index.html", []byte("<html>static</html>"))
+
+	for name, h := range handlersFor(t, b) {
+		t.Run(name, func(t *testing.T) {
+			w := serve(h, "GET", "/")
+			if w.Body.String() != "<html>root</html>" {
+				t.Error("root body", w.Body.String())
+			}
+			w = serve(h, "GET", "/static/")
+			if w.Body.String() != "<html>static</html>" {
+				t.Error("static body", w.Body.String())
+			}
+			if ct := w.Header().Get("Content-Type"); ct != "text/html; charset=utf-8" {
+				t.Error("content type", ct)
+			}
+			w = serve(h, "GET", "/static")
+			if w.Code != http.StatusMovedPermanently {
+				t.Error("want redirect but have", w.Code)
+			}
+			if loc := w.Header().Get("Location"); loc != "static/" {
+				t.Error("location", loc)
+			}
+		})
+	}
+}
+
+func TestHandlerUsesContentETags(t *testing.T) {
+	b := blob.New()
+	b.Append("index.html", []byte("<html>root</html>"))
+	b.Append("data", []byte("0123456789"))
+
+	for name, h := range handlersFor(t, b) {
+		t.Run(name, func(t *testing.T) {
+			etag := serve(h, "GET", "/data").Header().Get("ETag")
+			if len(etag) < 3 || etag[0] != '"' || etag[len(etag)-1] != '"' {
+				t.Fatal("want strong ETag but have", etag)
+			}
+			if other := serve(h, "GET", "/index.html").Header().Get("ETag"); other == etag {
+				t.Error("different content has same ETag")
+			}
+			w := serve(h, "GET", "/data", "If-None-Match", etag)
+			if w.Code != http.StatusNotModified {
+				t.Error("want 304 but have", w.Code)
+			}
+		})
+	}
+}
+
+func TestHandlerServesRanges(t *testing.T) {
+	b := blob.New()
+	b.Append("data", []byte("0123456789"))
+
+	for name, h := range handlersFor(t, b) {
+		t.Run(name, func(t *testing.T) {
+			w := serve(h, "GET", "/data", "Range", "bytes=2-4")
+			if w.Code != http.StatusPartialContent {
+				t.Fatal("want 206 but have", w.Code)
+			}
+			if w.Body.String() != "234" {
+				t.Error("body", w.Body.String())
+			}
+		})
+	}
+}
+
+// blockingSource is a Source whose item "slow" blocks reading until release
+// is closed.
+type blockingSource struct {
+	*blob.Blob
+	release chan struct{}
+}
+
+func (s blockingSource) Open(id string) (blob.ItemReader, error) {
+	r, err := s.Blob.Open(id)
+	if err == nil && id == "slow" {
+		r = blockingItem{r, s.release}
+	}
+	return r, err
+}
+
+type blockingItem struct {
+	blob.ItemReader
+	release chan struct{}
+}
+
+func (r blockingItem) Read(p []byte) (int, error) {
+	<-r.release
+	return r.ItemReader.Read(p)
+}
+
+func TestHandlerServesOtherItemsWhileComputingETag(t *testing.T) {
+	b := blob.New()
+	b.Append("slow", []byte("slow data"))
+	b.Append("fast", []byte("fast data"))
+	s := blockingSource{b, make(chan struct{})}
+	h := blob.Handler(s)
+	serve(h, "GET", "/fast")
+
+	slow := make(chan *httptest.ResponseRecorder)
+	go func() { slow <- serve(h, "GET", "/slow") }()
+	fast := make(chan *httptest.ResponseRecorder)
+	go func() { fast <- serve(h, "GET", "/fast") }()
+
+	select {
+	case w := <-fast:
+		if w.Body.String() != "fast data" {
+			t.Error("body", w.Body.String())
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("request for a cached ETag waited for another item")
+	}
+	close(s.release)
+	if w := <-slow; w.Body.String() != "slow data" {
+		t.Error("body", w.Body.String())
+	}
+}
+
+func TestHandlerServesOverlayOfBlobReadersInParallel(t *testing.T) {
+	lower := blob.New()
+	upper := blob.New()
+	for i := 0; i < 10; i++ {
+		id := string(rune('a' + i))
+		lower.Append(id, bytes.Repeat([]byte(id), 1000))
+		upper.Append(id+id, bytes.Repeat([]byte(id+id), 1000))
+	}
+	o := blob.NewOverlay()
+	o.PushBlobReader(openBlob(t, lower))
+	o.PushBlobReader(openBlob(t, upper))
+	h := blob.Handler(o)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			id := string(rune('a' + i%10))
+			if i%2 == 1 {
+				id += id
+			}
+			w := serve(h, "GET", "/"+id)
+			if w.Body.String() != strings.Repeat(id, 1000) {
+				t.Errorf("%s: wrong body", id)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestHandlerUpdatesETagWhenOverlayChanges(t *testing.T) {
+	base := blob.New()
+	base.Append("a", []byte("old"))
+	o := blob.NewOverlay()
+	o.PushBlob(base)
+	h := blob.Handler(o)
+	oldETag := serve(h, "GET", "/a").Header().Get("ETag")
+
+	patch := blob.New()
+	patch.Append("a", []byte("new"))
+	o.PushBlob(patch)
+
+	w := serve(h, "GET", "/a", "If-None-Match", oldETag)
+	if w.Code != http.StatusOK || w.Body.String() != "new" {
+		t.Errorf("want the new data but have %d %q", w.Code, w.Body.String())
+	}
+	if etag := w.Header().Get("ETag"); etag == oldETag {
+		t.Error("the ETag did not change with the data")
+	}
+}
+
+func TestHandlerServesOverlayWhileLayersArePushed(t *testing.T) {
+	base := blob.New()
+	base.Append("a", []byte("0"))
+	o := blob.NewOverlay()
+	o.PushBlob(base)
+	h := blob.Handler(o)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w := serve(h, "GET", "/a")
+			if w.Code != http.StatusOK || w.Body.Len() == 0 {
+				t.Errorf("want data but have %d %q", w.Code, w.Body.String())
+			}
+		}()
+	}
+	for i := 1; i <= 10; i++ {
+		layer := blob.New()
+		layer.Append("a", []byte(strconv.Itoa(i)))
+		o.PushBlob(layer)
+	}
+	wg.Wait()
+
+	w := serve(h, "GET", "/a")
+	if w.Body.String() != "10" {
+		t.Errorf("want the top layer's data but have %q", w.Body.String())
+	}
+}
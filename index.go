@@ -0,0 +1,76 @@
+package blob
+
+import (
+	"errors"
+	"io"
+)
+
+// Index is the header of a blob without its data. It tells which items a blob
+// contains, where their data is stored and how large it is. Use ReadIndex to
+// get the index of a blob without loading or opening the whole blob.
+type Index struct {
+	header
+}
+
+// ReadIndex reads only the header of a blob from r. It stops reading right
+// before the item data, r is not read any further. See Write for a description
+// of the data format.
+//
+// For a blob that was changed with Edit, the header is stored after the data,
+// which is skipped, by seeking if r is an io.Seeker.
+func ReadIndex(r io.Reader) (*Index, error) {
+	return ReadIndexWithOptions(r, ReadOptions{})
+}
+
+// ReadIndexWithOptions reads the header of a blob like ReadIndex but fails if
+// it exceeds the limits given in opts.
+func ReadIndexWithOptions(r io.Reader, opts ReadOptions) (*Index, error) {
+	h, _, err := readHeader(r, opts)
+	if err != nil {
+		return nil, err
+	}
+	if h.indexOffset != 0 {
+		// skip the data to get to the header at the end
+		skip := int64(h.indexOffset - trailingDataStart)
+		if s, ok := r.(io.Seeker); ok {
+			_, err = s.Seek(skip, io.SeekCurrent)
+		} else {
+			_, err = io.CopyN(io.Discard, r, skip)
+		}
+		if err != nil {
+			return nil, errors.New("read blob index: " + err.Error())
+		}
+		h, _, _, err = readTrailingIndex(r, h.indexOffset, opts)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &Index{header: h}, nil
+}
+
+// Items returns the descriptions of all items, in index order.
+func (h *header) Items() []Item {
+	items := make([]Item, len(h.items))
+	for i := range items {
+		items[i] = h.itemAt(i)
+	}
+	return items
+}
+
+// ItemAt returns the description of the item at index i. If the index is out
+// of bounds, found will be false. Call ItemCount for the number of items.
+func (h *header) ItemAt(i int) (item Item, found bool) {
+	if i < 0 || i >= len(h.items) {
+		return
+	}
+	return h.itemAt(i), true
+}
+
+// Lookup returns the description of the first item with the given ID. If there
+// is no such item, found will be false.
+func (h *header) Lookup(id string) (item Item, found bool) {
+	if i, ok := h.find(id); ok {
+		return h.itemAt(i), true
+	}
+	return
+}
@@ -0,0 +1,86 @@
+package blob_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/gonutz/blob"
+)
+
+func TestReadIndexReadsOnlyTheHeader(t *testing.T) {
+	b := blob.New()
+	b.Append("a", []byte("123"))
+	b.Append("bb", []byte("45"))
+	var buf bytes.Buffer
+	b.Write(&buf)
+	// header: 4 bytes length, 2+1+8 bytes for "a", 2+2+8 bytes for "bb"
+	r := bytes.NewReader(buf.Bytes())
+
+	index, err := blob.ReadIndex(r)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Len() != 5 {
+		t.Error("want the 5 data bytes to be left unread but have", r.Len())
+	}
+	want := []blob.Item{
+		{ID: "a", Offset: 27, Size: 3},
+		{ID: "bb", Offset: 30, Size: 2},
+	}
+	items := index.Items()
+	if len(items) != len(want) {
+		t.Fatal("want", want, "but have", items)
+	}
+	for i := range want {
+		if items[i] != want[i] {
+			t.Error("item", i, "want", want[i], "but have", items[i])
+		}
+	}
+}
+
+func TestItemOffsetsPointIntoWrittenBlob(t *testing.T) {
+	b := blob.New()
+	b.Append("first", []byte("hello"))
+	b.Append("second", []byte("world!"))
+	var buf bytes.Buffer
+	b.Write(&buf)
+	br, err := blob.Open(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, id := range []string{"first", "second"} {
+		fromBlob, ok := b.Lookup(id)
+		if !ok {
+			t.Fatal(id, "not found in Blob")
+		}
+		fromReader, ok := br.Lookup(id)
+		if !ok {
+			t.Fatal(id, "not found in BlobReader")
+		}
+		if fromBlob != fromReader {
+			t.Error("Blob and BlobReader disagree:", fromBlob, fromReader)
+		}
+		data, _ := b.GetByID(id)
+		checkBytes(t, buf.Bytes()[fromBlob.Offset:fromBlob.Offset+fromBlob.Size], data)
+	}
+}
+
+func TestItemAtAndLookupReportMissingItems(t *testing.T) {
+	b := blob.New()
+	b.Append("id", []byte("data"))
+
+	if item, ok := b.ItemAt(0); !ok || item.ID != "id" || item.Size != 4 {
+		t.Error("unexpected item at 0:", item, ok)
+	}
+	if _, ok := b.ItemAt(-1); ok {
+		t.Error("index -1 must not be found")
+	}
+	if _, ok := b.ItemAt(1); ok {
+		t.Error("index 1 must not be found")
+	}
+	if _, ok := b.Lookup("missing"); ok {
+		t.Error("missing ID must not be found")
+	}
+}
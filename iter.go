@@ -0,0 +1,200 @@
+package blob
+
+import (
+	"io"
+	"iter"
+	"path"
+	"sort"
+	"strings"
+)
+
+// Item describes one entry of a blob without its data.
+type Item struct {
+	// ID is the string ID the entry was stored under.
+	ID string
+	// Offset is the position of the entry's data in bytes, counted from the
+	// start of the blob. For a Blob this is where the data ends up when
+	// writing it with Write.
+	Offset int64
+	// Size is the length of the entry's data in bytes.
+	Size int64
+}
+
+// indices returns an iterator over the indices of all entries whose IDs match.
+func (h *header) indices(match func(id string) bool) iter.Seq[int] {
+	return func(yield func(int) bool) {
+		for i := range h.items {
+			if match(h.items[i].id) && !yield(i) {
+				return
+			}
+		}
+	}
+}
+
+// inRange returns an iterator over the indices of all entries with
+// from <= ID < to, in lexical order of the IDs. An empty to means that there
+// is no upper bound.
+func (h *header) inRange(from, to string) iter.Seq[int] {
+	inside := func(id string) bool {
+		return id >= from && (to == "" || id < to)
+	}
+	return func(yield func(int) bool) {
+		if h.sorted {
+			i := sort.Search(len(h.items), func(i int) bool {
+				return h.items[i].id >= from
+			})
+			for ; i < len(h.items) && inside(h.items[i].id); i++ {
+				if !yield(i) {
+					return
+				}
+			}
+			return
+		}
+
+		var indices []int
+		for i := range h.indices(inside) {
+			indices = append(indices, i)
+		}
+		sort.SliceStable(indices, func(i, j int) bool {
+			return h.items[indices[i]].id < h.items[indices[j]].id
+		})
+		for _, i := range indices {
+			if !yield(i) {
+				return
+			}
+		}
+	}
+}
+
+// itemAt returns the description of the entry at the valid index i.
+func (h *header) itemAt(i int) Item {
+	offset := 4 + h.length + h.items[i].start
+	if h.indexOffset != 0 {
+		offset = h.items[i].start
+	}
+	return Item{
+		ID:     h.items[i].id,
+		Offset: int64(offset),
+		Size:   int64(h.items[i].end - h.items[i].start),
+	}
+}
+
+func matchAll(string) bool { return true }
+
+func hasPrefix(prefix string) func(string) bool {
+	return func(id string) bool {
+		return strings.HasPrefix(id, prefix)
+	}
+}
+
+// globMatcher checks the pattern once so that matching in the iterators can
+// not fail later on.
+func globMatcher(pattern string) (func(string) bool, error) {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, err
+	}
+	return func(id string) bool {
+		ok, _ := path.Match(pattern, id)
+		return ok
+	}, nil
+}
+
+// All returns an iterator over all entries of the blob in index order. It
+// yields each ID together with its data. The data is nil for entries added
+// with AppendReader or AppendFile that can not be read, use ReadAll to get the
+// error. The same holds for the other iterators.
+//
+// Example:
+//
+//	for id, data := range b.All() {
+//	    fmt.Println(id, len(data))
+//	}
+func (b *Blob) All() iter.Seq2[string, []byte] {
+	return b.each(b.indices(matchAll))
+}
+
+// WithPrefix returns an iterator over all entries whose IDs start with the
+// given prefix, in index order.
+func (b *Blob) WithPrefix(prefix string) iter.Seq2[string, []byte] {
+	return b.each(b.indices(hasPrefix(prefix)))
+}
+
+// Glob returns an iterator over all entries whose IDs match the given shell
+// pattern, in index order. See path.Match for the pattern syntax. The only
+// possible error is path.ErrBadPattern, in which case the iterator is nil.
+func (b *Blob) Glob(pattern string) (iter.Seq2[string, []byte], error) {
+	match, err := globMatcher(pattern)
+	if err != nil {
+		return nil, err
+	}
+	return b.each(b.indices(match)), nil
+}
+
+// Range returns an iterator over all entries with from <= ID < to, in lexical
+// order of the IDs. Entries with equal IDs keep their index order. An empty to
+// means that there is no upper bound.
+//
+// Range is fast for blobs that are sorted by ID, see WriteOptions.SortIDs.
+// For other blobs it has to go through and sort all IDs first.
+func (b *Blob) Range(from, to string) iter.Seq2[string, []byte] {
+	return b.each(b.inRange(from, to))
+}
+
+func (b *Blob) each(indices iter.Seq[int]) iter.Seq2[string, []byte] {
+	return func(yield func(string, []byte) bool) {
+		for i := range indices {
+			// unreadable lazy items are documented to yield nil
+			data, _ := b.item(i)
+			if !yield(b.items[i].id, data) {
+				return
+			}
+		}
+	}
+}
+
+// All returns an iterator over all entries of the blob in index order. It
+// yields a description of each entry together with a reader for its data. See
+// Open for the restrictions on using these readers.
+//
+// Breaking out of the loop stops the iteration right away, no more readers
+// are created after that.
+func (b *BlobReader) All() iter.Seq2[Item, io.ReadSeeker] {
+	return b.each(b.indices(matchAll))
+}
+
+// WithPrefix returns an iterator over all entries whose IDs start with the
+// given prefix, in index order.
+func (b *BlobReader) WithPrefix(prefix string) iter.Seq2[Item, io.ReadSeeker] {
+	return b.each(b.indices(hasPrefix(prefix)))
+}
+
+// Glob returns an iterator over all entries whose IDs match the given shell
+// pattern, in index order. See path.Match for the pattern syntax. The only
+// possible error is path.ErrBadPattern, in which case the iterator is nil.
+func (b *BlobReader) Glob(pattern string) (iter.Seq2[Item, io.ReadSeeker], error) {
+	match, err := globMatcher(pattern)
+	if err != nil {
+		return nil, err
+	}
+	return b.each(b.indices(match)), nil
+}
+
+// Range returns an iterator over all entries with from <= ID < to, in lexical
+// order of the IDs. Entries with equal IDs keep their index order. An empty to
+// means that there is no upper bound.
+//
+// Range is fast for blobs that are sorted by ID, see WriteOptions.SortIDs.
+// For other blobs it has to go through and sort all IDs first.
+func (b *BlobReader) Range(from, to string) iter.Seq2[Item, io.ReadSeeker] {
+	return b.each(b.inRange(from, to))
+}
+
+func (b *BlobReader) each(indices iter.Seq[int]) iter.Seq2[Item, io.ReadSeeker] {
+	return func(yield func(Item, io.ReadSeeker) bool) {
+		for i := range indices {
+			if !yield(b.itemAt(i), b.item(i)) {
+				return
+			}
+		}
+	}
+}
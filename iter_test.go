@@ -0,0 +1,148 @@
+package blob_test
+
+import (
+	"io"
+	"path"
+	"testing"
+
+	"github.com/gonutz/blob"
+)
+
+func TestBlobAllIteratesInIndexOrder(t *testing.T) {
+	b := blob.New()
+	b.Append("static/a.png", []byte{1})
+	b.Append("index.html", []byte{2, 3})
+	b.Append("static/b.png", []byte{4, 5, 6})
+	b.Append("static/c.css", nil)
+	var ids []string
+	var data []byte
+
+	for id, d := range b.All() {
+		ids = append(ids, id)
+		data = append(data, d...)
+	}
+
+	checkStrings(t, ids, []string{
+		"static/a.png", "index.html", "static/b.png", "static/c.css",
+	})
+	checkBytes(t, data, []byte{1, 2, 3, 4, 5, 6})
+}
+
+func TestBlobIteratorsFilterIDs(t *testing.T) {
+	b := blob.New()
+	b.Append("static/a.png", nil)
+	b.Append("index.html", nil)
+	b.Append("static/b.png", nil)
+	b.Append("static/c.css", nil)
+
+	var ids []string
+	for id := range b.WithPrefix("static/") {
+		ids = append(ids, id)
+	}
+	checkStrings(t, ids, []string{"static/a.png", "static/b.png", "static/c.css"})
+
+	pngs, err := b.Glob("*/*.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids = nil
+	for id := range pngs {
+		ids = append(ids, id)
+	}
+	checkStrings(t, ids, []string{"static/a.png", "static/b.png"})
+}
+
+func TestGlobReportsBadPattern(t *testing.T) {
+	b := blob.New()
+	b.Append("a", nil)
+
+	seq, err := b.Glob("[")
+
+	if err != path.ErrBadPattern {
+		t.Error("want bad pattern error but got", err)
+	}
+	if seq != nil {
+		t.Error("want nil iterator after error")
+	}
+}
+
+func TestBreakingOutOfBlobIteratorStops(t *testing.T) {
+	b := blob.New()
+	b.Append("a", nil)
+	b.Append("b", nil)
+	n := 0
+
+	for range b.All() {
+		n++
+		break
+	}
+
+	if n != 1 {
+		t.Error("want 1 iteration but have", n)
+	}
+}
+
+func TestBlobReaderIterators(t *testing.T) {
+	b := blob.New()
+	b.Append("static/a.png", []byte{1})
+	b.Append("index.html", []byte{2, 3})
+	b.Append("static/b.png", []byte{4, 5, 6})
+	b.Append("static/c.css", nil)
+	br := openBlob(t, b)
+
+	var items []blob.Item
+	var data []byte
+	for item, r := range br.WithPrefix("static/") {
+		items = append(items, item)
+		all, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data = append(data, all...)
+	}
+	want := []blob.Item{
+		{ID: "static/a.png", Offset: 90, Size: 1},
+		{ID: "static/b.png", Offset: 93, Size: 3},
+		{ID: "static/c.css", Offset: 96, Size: 0},
+	}
+	if len(items) != len(want) {
+		t.Fatal("want", want, "but have", items)
+	}
+	for i := range want {
+		if items[i] != want[i] {
+			t.Error("item", i, "want", want[i], "but have", items[i])
+		}
+	}
+	checkBytes(t, data, []byte{1, 4, 5, 6})
+
+	n := 0
+	for range br.All() {
+		n++
+		if n == 2 {
+			break
+		}
+	}
+	if n != 2 {
+		t.Error("want 2 iterations but have", n)
+	}
+
+	css, err := br.Glob("*.css")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for item := range css {
+		t.Error("top-level glob must not match", item.ID)
+	}
+}
+
+func checkStrings(t *testing.T, got, want []string) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("want %q but got %q", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("want %q but got %q", want, got)
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package blob
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync"
+)
+
+// AppendReader adds an item whose data is read from r when the blob is
+// written, instead of keeping it in memory. size is the length of the data, it
+// is an error if r does not provide exactly size bytes when it is read.
+//
+// If r is an io.Seeker, its current position is remembered and r is read from
+// there every time the data is needed, so the blob can be written more than
+// once. Otherwise r can only be read once. Accessing the data before writing,
+// e.g. with GetByID, then keeps it in memory, but writing the blob a second
+// time fails.
+//
+// GetByID and GetByIndex report the item as not found if reading its data
+// fails, iterators like All yield nil data. Use ReadAll to get the error
+// instead. Reading the data is safe for concurrent use.
+func (b *Blob) AppendReader(id string, r io.Reader, size int64) error {
+	if size < 0 {
+		return errors.New("blob.Blob.AppendReader: negative size")
+	}
+	item := &lazyItem{r: r, pos: -1}
+	if s, ok := r.(io.Seeker); ok {
+		pos, err := s.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return itemError("blob.Blob.AppendReader", id, err)
+		}
+		item.pos = pos
+	}
+	b.appendLazy(id, item, uint64(size))
+	return nil
+}
+
+// AppendFile adds an item whose data is read from the file at the given path
+// when the blob is written, instead of keeping it in memory. The file's size
+// is determined right away, it is an error if the file has a different size
+// when it is read. See AppendReader for accessing the data before writing.
+func (b *Blob) AppendFile(id, path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return itemError("blob.Blob.AppendFile", id, err)
+	}
+	if !info.Mode().IsRegular() {
+		return itemError("blob.Blob.AppendFile", id, errors.New(path+" is not a regular file"))
+	}
+	b.appendLazy(id, &lazyItem{path: path}, uint64(info.Size()))
+	return nil
+}
+
+func (b *Blob) appendLazy(id string, item *lazyItem, size uint64) {
+	if b.lazyMu == nil {
+		b.lazyMu = new(sync.Mutex)
+	}
+	b.sorted = len(b.items) == 0 ||
+		b.sorted && b.items[len(b.items)-1].id <= id
+	b.length += 2 + uint64(len(id)) + 8
+	item.start = b.dataSize()
+	item.shift = size
+	if len(b.lazy) > 0 {
+		item.shift += b.lazy[len(b.lazy)-1].shift
+	}
+	b.items = append(b.items, indexItem{
+		id:    id,
+		start: item.start,
+		end:   item.start + size,
+		lazy:  item,
+	})
+	b.lazy = append(b.lazy, item)
+}
+
+// lazyItem is the source of an item added with AppendReader or AppendFile.
+type lazyItem struct {
+	// start is the item's offset in the blob's data.
+	start uint64
+	// shift is the sum of the sizes of this and all earlier lazy items.
+	shift uint64
+
+	path string
+	r    io.Reader
+	// pos is the position to seek r to before reading, -1 if r can not seek.
+	pos int64
+	// read is set once r was read if it can not seek, data then holds its
+	// data if it was read for access. Both are guarded by the blob's lazyMu.
+	read bool
+	data []byte
+}
+
+// dataSize returns the overall size of the data of all items.
+func (b *Blob) dataSize() uint64 {
+	size := uint64(len(b.data))
+	if len(b.lazy) > 0 {
+		size += b.lazy[len(b.lazy)-1].shift
+	}
+	return size
+}
+
+// dataOffset returns the position in b.data of an item that is in memory.
+// Lazy items before it take no space in b.data, so its start is shifted by
+// their sizes.
+func (b *Blob) dataOffset(item *indexItem) uint64 {
+	i := sort.Search(len(b.lazy), func(i int) bool {
+		return b.lazy[i].start >= item.start
+	})
+	if i == 0 {
+		return item.start
+	}
+	return item.start - b.lazy[i-1].shift
+}
+
+// load returns the data of the given item, reading it if it is lazy.
+func (b *Blob) load(item *indexItem) ([]byte, error) {
+	if item.lazy == nil {
+		start := b.dataOffset(item)
+		return b.data[start : start+item.end-item.start], nil
+	}
+	b.lazyMu.Lock()
+	defer b.lazyMu.Unlock()
+	if item.lazy.data != nil {
+		return item.lazy.data, nil
+	}
+	var buf bytes.Buffer
+	buf.Grow(int(item.end - item.start))
+	if err := item.lazy.write(&buf, item); err != nil {
+		return nil, err
+	}
+	data := buf.Bytes()
+	if item.lazy.pos < 0 && item.lazy.path == "" {
+		// the reader can not be read again so keep the data
+		item.lazy.data = append([]byte{}, data...)
+		data = item.lazy.data
+	}
+	return data, nil
+}
+
+// writeItem writes the data of the given item to w, streaming it if it is
+// lazy.
+func (b *Blob) writeItem(w io.Writer, item *indexItem) error {
+	if item.lazy == nil {
+		start := b.dataOffset(item)
+		_, err := w.Write(b.data[start : start+item.end-item.start])
+		return err
+	}
+	b.lazyMu.Lock()
+	defer b.lazyMu.Unlock()
+	return item.lazy.write(w, item)
+}
+
+// write writes the data of the given lazy item to w. The caller must hold the
+// blob's lazyMu.
+func (l *lazyItem) write(w io.Writer, item *indexItem) error {
+	if l.data != nil {
+		_, err := w.Write(l.data)
+		return err
+	}
+
+	r, err := l.open()
+	if err != nil {
+		return itemError("blob", item.id, err)
+	}
+	defer r.Close()
+	src := &namedReader{r: r, id: item.id}
+	size := int64(item.end - item.start)
+	// errors from w are returned as they are, w names the item if necessary
+	if n, err := io.CopyN(w, src, size); err == io.EOF {
+		return fmt.Errorf("blob: item %q has %d instead of %d bytes", item.id, n, size)
+	} else if err != nil {
+		return err
+	}
+	var more [1]byte
+	if n, _ := io.ReadFull(src, more[:]); n > 0 {
+		return fmt.Errorf("blob: item %q has more than %d bytes", item.id, size)
+	}
+	return nil
+}
+
+// open returns a reader for the item's data.
+func (l *lazyItem) open() (io.ReadCloser, error) {
+	if l.path != "" {
+		return os.Open(l.path)
+	}
+	if l.pos >= 0 {
+		if _, err := l.r.(io.Seeker).Seek(l.pos, io.SeekStart); err != nil {
+			return nil, err
+		}
+		return io.NopCloser(l.r), nil
+	}
+	if l.read {
+		return nil, errors.New("the reader was already read")
+	}
+	l.read = true
+	return io.NopCloser(l.r), nil
+}
+
+// namedReader adds the item ID to errors from r, except for io.EOF.
+type namedReader struct {
+	r  io.Reader
+	id string
+}
+
+func (r *namedReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if err != nil && err != io.EOF {
+		err = itemError("blob", r.id, err)
+	}
+	return n, err
+}
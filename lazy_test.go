@@ -0,0 +1,184 @@
+package blob_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gonutz/blob"
+)
+
+func TestLazyItemsAreWrittenInPlace(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "file")
+	writeTestFile(t, path, "from file")
+	b := blob.New()
+	b.Append("a", []byte("first"))
+	if err := b.AppendReader("b", strings.NewReader("from reader"), 11); err != nil {
+		t.Fatal(err)
+	}
+	b.Append("c", []byte("middle"))
+	if err := b.AppendFile("d", path); err != nil {
+		t.Fatal(err)
+	}
+	b.Append("e", []byte("last"))
+
+	var buf bytes.Buffer
+	n, err := b.WriteTo(&buf)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != b.Size() {
+		t.Error("Size returned", b.Size(), "but", n, "bytes were written")
+	}
+	written, err := blob.Read(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkBlobContent(t, written,
+		"a", "first",
+		"b", "from reader",
+		"c", "middle",
+		"d", "from file",
+		"e", "last",
+	)
+	checkBlobContent(t, b,
+		"a", "first",
+		"b", "from reader",
+		"c", "middle",
+		"d", "from file",
+		"e", "last",
+	)
+}
+
+func TestLazyItemsInSortedOutput(t *testing.T) {
+	b := blob.New()
+	b.Append("z", []byte("z"))
+	b.AppendReader("y", strings.NewReader("yy"), 2)
+	b.Append("x", []byte("xxx"))
+
+	var buf bytes.Buffer
+	if err := b.WriteWithOptions(&buf, blob.WriteOptions{SortIDs: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	written, err := blob.Read(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkBlobContent(t, written, "x", "xxx", "y", "yy", "z", "z")
+}
+
+func TestLazyItemSizeMustMatch(t *testing.T) {
+	for _, size := range []int64{2, 4} {
+		b := blob.New()
+		b.AppendReader("id", strings.NewReader("abc"), size)
+		err := b.Write(io.Discard)
+		if err == nil || !strings.Contains(err.Error(), `"id"`) {
+			t.Error("size", size, "want error naming the item but have", err)
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "file")
+	writeTestFile(t, path, "abc")
+	b := blob.New()
+	b.AppendFile("file", path)
+	writeTestFile(t, path, "abcd")
+	if err := b.Write(io.Discard); err == nil {
+		t.Error("error expected for changed file")
+	}
+}
+
+func TestLazyReadersThatCanSeekAreReadAgain(t *testing.T) {
+	seeker := strings.NewReader("skip:data")
+	seeker.Seek(5, io.SeekStart)
+	plain := io.MultiReader(strings.NewReader("once"))
+	b := blob.New()
+	b.AppendReader("seeker", seeker, 4)
+	b.AppendReader("plain", plain, 4)
+
+	if err := b.Write(io.Discard); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Write(io.Discard); err == nil {
+		t.Error("a plain reader can only be written once")
+	}
+	data, err := b.ReadAll("seeker")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkBytes(t, data, []byte("data"))
+}
+
+func TestLazyPlainReaderIsKeptAfterAccess(t *testing.T) {
+	b := blob.New()
+	b.AppendReader("plain", io.MultiReader(strings.NewReader("data")), 4)
+
+	checkBlobContent(t, b, "plain", "data")
+	var buf bytes.Buffer
+	if err := b.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	written, _ := blob.Read(&buf)
+	checkBlobContent(t, written, "plain", "data")
+}
+
+func TestAppendFileFailsForMissingFile(t *testing.T) {
+	b := blob.New()
+	if err := b.AppendFile("id", filepath.Join(t.TempDir(), "missing")); !errors.Is(err, os.ErrNotExist) {
+		t.Error("want not exist error but have", err)
+	}
+	if b.ItemCount() != 0 {
+		t.Error("nothing must be appended on error")
+	}
+}
+
+func TestUnreadableLazyItemIsNotFound(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "file")
+	writeTestFile(t, path, "data")
+	b := blob.New()
+	if err := b.AppendFile("file", path); err != nil {
+		t.Fatal(err)
+	}
+	os.Remove(path)
+
+	if data, found := b.GetByID("file"); found || data != nil {
+		t.Errorf("want the item not to be found but have %q, %v", data, found)
+	}
+	if _, found := b.GetByIndex(0); found {
+		t.Error("want the item not to be found by index")
+	}
+	if _, err := b.ReadAll("file"); !errors.Is(err, os.ErrNotExist) {
+		t.Error("want not exist error but have", err)
+	}
+	o := blob.NewOverlay()
+	o.PushBlob(b)
+	if _, err := o.Open("file"); !errors.Is(err, os.ErrNotExist) {
+		t.Error("want not exist error from the overlay but have", err)
+	}
+}
+
+func TestLazyItemsCanBeReadConcurrently(t *testing.T) {
+	b := blob.New()
+	b.AppendReader("plain", io.MultiReader(strings.NewReader("data")), 4)
+	b.AppendReader("seeker", strings.NewReader("seek"), 4)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for id, want := range map[string]string{"plain": "data", "seeker": "seek"} {
+				if data, found := b.GetByID(id); !found || string(data) != want {
+					t.Errorf("%s: want %q but have %q, %v", id, want, data, found)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,139 @@
+package blob_test
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/gonutz/blob"
+)
+
+func TestHugeHeaderLengthFromShortInputFails(t *testing.T) {
+	// claims a header of almost 1 GB but the input ends right away
+	input := []byte{0xFF, 0xFF, 0xFF, 0x3F, 1, 2, 3}
+	b, err := blob.Read(bytes.NewReader(input))
+	if err == nil || !strings.Contains(err.Error(), "unexpected EOF") {
+		t.Error("want unexpected EOF but got", err)
+	}
+	if b != nil {
+		t.Error("valid b after error")
+	}
+}
+
+func TestHugeDataLengthFromShortInputFails(t *testing.T) {
+	input := []byte{
+		11, 0, 0, 0,
+		1, 0, 'a',
+		0, 0, 0, 0, 0, 0, 0, 1, // 2^56 bytes of data
+		1, 2, 3,
+	}
+	if _, err := blob.Read(bytes.NewReader(input)); err == nil {
+		t.Error("error expected for Read")
+	}
+	if _, err := blob.Open(bytes.NewReader(input)); err == nil {
+		t.Error("error expected for Open")
+	}
+}
+
+func TestOverflowingDataLengthsFail(t *testing.T) {
+	input := []byte{
+		22, 0, 0, 0,
+		1, 0, 'a', 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF,
+		1, 0, 'b', 2, 0, 0, 0, 0, 0, 0, 0,
+	}
+	_, err := blob.Read(bytes.NewReader(input))
+	if err == nil || !strings.Contains(err.Error(), "too large") {
+		t.Error("want error about size but got", err)
+	}
+}
+
+func TestHeaderIsReadCompletelyFromShortReads(t *testing.T) {
+	b := blob.New()
+	b.Append("one", []byte{1, 2, 3})
+	b.Append("two", []byte{4, 5})
+	var buf bytes.Buffer
+	b.Write(&buf)
+
+	read, err := blob.Read(&oneByteReader{r: bytes.NewReader(buf.Bytes())})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if read.ItemCount() != 2 {
+		t.Fatal("want 2 items but have", read.ItemCount())
+	}
+	two, _ := read.GetByID("two")
+	checkBytes(t, two, []byte{4, 5})
+}
+
+type oneByteReader struct {
+	r io.Reader
+}
+
+func (r *oneByteReader) Read(p []byte) (int, error) {
+	if len(p) > 1 {
+		p = p[:1]
+	}
+	return r.r.Read(p)
+}
+
+func TestReadOptionsLimitInput(t *testing.T) {
+	b := blob.New()
+	b.Append("one", []byte{1, 2, 3})
+	b.Append("two", []byte{4, 5})
+	var buf bytes.Buffer
+	b.Write(&buf)
+	// header: 2+3+8 bytes per item
+
+	tests := []struct {
+		opts    blob.ReadOptions
+		wantErr bool
+	}{
+		{blob.ReadOptions{}, false},
+		{blob.ReadOptions{MaxHeaderSize: 26, MaxItemCount: 2, MaxDataSize: 5}, false},
+		{blob.ReadOptions{MaxHeaderSize: 25}, true},
+		{blob.ReadOptions{MaxItemCount: 1}, true},
+		{blob.ReadOptions{MaxDataSize: 4}, true},
+	}
+	for _, test := range tests {
+		_, err := blob.ReadWithOptions(bytes.NewReader(buf.Bytes()), test.opts)
+		if (err != nil) != test.wantErr {
+			t.Errorf("Read with %+v: unexpected error %v", test.opts, err)
+		}
+		_, err = blob.OpenWithOptions(bytes.NewReader(buf.Bytes()), test.opts)
+		if (err != nil) != test.wantErr {
+			t.Errorf("Open with %+v: unexpected error %v", test.opts, err)
+		}
+	}
+}
+
+func TestOpenFailsIfDataIsTruncated(t *testing.T) {
+	b := blob.New()
+	b.Append("one", []byte{1, 2, 3})
+	var buf bytes.Buffer
+	b.Write(&buf)
+	data := buf.Bytes()
+
+	_, err := blob.Open(bytes.NewReader(data[:len(data)-1]))
+	if err == nil || !strings.Contains(err.Error(), "beyond the end") {
+		t.Error("want error about truncated data but got", err)
+	}
+}
+
+func TestReadingLargeDataGrowsBufferToExactSize(t *testing.T) {
+	data := make([]byte, 3<<20+5)
+	for i := range data {
+		data[i] = byte(i % 251)
+	}
+	b := blob.New()
+	b.Append("large", data)
+	var buf bytes.Buffer
+	b.Write(&buf)
+
+	read, err := blob.Read(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	large, _ := read.GetByID("large")
+	checkBytes(t, large, data)
+}
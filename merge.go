@@ -0,0 +1,91 @@
+package blob
+
+import (
+	"errors"
+	"io"
+	"strconv"
+)
+
+// ConflictPolicy decides which item Merge keeps if more than one source
+// contains the same ID.
+type ConflictPolicy int
+
+const (
+	// FirstWins keeps the item of the first source that contains the ID.
+	FirstWins ConflictPolicy = iota
+	// LastWins keeps the item of the last source that contains the ID.
+	LastWins
+	// ErrorOnConflict makes Merge fail if more than one source contains the
+	// same ID.
+	ErrorOnConflict
+)
+
+// Merge writes a new blob to dst that contains the items of all srcs. If more
+// than one source contains the same ID, the item of the first source is kept.
+// See MergeWithPolicy for details.
+func Merge(dst io.Writer, srcs ...*BlobReader) error {
+	return MergeWithPolicy(dst, FirstWins, srcs...)
+}
+
+// MergeWithPolicy writes a new blob to dst that contains the items of all
+// srcs. Each ID appears only once in the result, the given policy decides
+// which source it is taken from. Only the first item with an ID is considered
+// in each source, just like GetByID would find it. The items are written in
+// the order in which their IDs first appear in the sources.
+//
+// The item data is streamed from the sources, they are not loaded into memory
+// at once. See Open for the restrictions on reading from the sources.
+func MergeWithPolicy(dst io.Writer, policy ConflictPolicy, srcs ...*BlobReader) error {
+	if policy < FirstWins || policy > ErrorOnConflict {
+		return errors.New("blob.Merge: invalid conflict policy " + strconv.Itoa(int(policy)))
+	}
+
+	type mergeItem struct {
+		src   *BlobReader
+		index int
+	}
+	var ids []string
+	winners := make(map[string]mergeItem)
+	for _, src := range srcs {
+		inSource := make(map[string]bool)
+		for i, item := range src.items {
+			if inSource[item.id] {
+				continue
+			}
+			inSource[item.id] = true
+
+			if _, ok := winners[item.id]; !ok {
+				ids = append(ids, item.id)
+				winners[item.id] = mergeItem{src, i}
+			} else if policy == LastWins {
+				winners[item.id] = mergeItem{src, i}
+			} else if policy == ErrorOnConflict {
+				return errors.New("blob.Merge: ID " + strconv.Quote(item.id) + " is in more than one source")
+			}
+		}
+	}
+
+	items := make([]indexItem, len(ids))
+	var offset uint64
+	for i, id := range ids {
+		w := winners[id]
+		size := w.src.items[w.index].end - w.src.items[w.index].start
+		items[i] = indexItem{id: id, start: offset, end: offset + size}
+		offset += size
+	}
+	headerData, err := encodeHeader(items, false)
+	if err != nil {
+		return errors.New("blob.Merge: " + err.Error())
+	}
+	if _, err := dst.Write(headerData); err != nil {
+		return errors.New("blob.Merge: write header: " + err.Error())
+	}
+
+	for _, id := range ids {
+		w := winners[id]
+		if err := w.src.copyItem(dst, w.index); err != nil {
+			return errors.New("blob.Merge: copy " + strconv.Quote(id) + ": " + err.Error())
+		}
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+package blob_test
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/gonutz/blob"
+)
+
+func openBlob(t *testing.T, b *blob.Blob) *blob.BlobReader {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := b.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	br, err := blob.Open(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return br
+}
+
+func checkBlobContent(t *testing.T, b *blob.Blob, idsAndData ...string) {
+	t.Helper()
+	if b.ItemCount()*2 != len(idsAndData) {
+		t.Fatalf("want %d items but have %d", len(idsAndData)/2, b.ItemCount())
+	}
+	for i := 0; i < len(idsAndData); i += 2 {
+		if id := b.GetIDAtIndex(i / 2); id != idsAndData[i] {
+			t.Errorf("item %d: want ID %q but have %q", i/2, idsAndData[i], id)
+		}
+		data, _ := b.GetByIndex(i / 2)
+		if string(data) != idsAndData[i+1] {
+			t.Errorf("item %d: want data %q but have %q", i/2, idsAndData[i+1], data)
+		}
+	}
+}
+
+func TestMergeKeepsFirstItemByDefault(t *testing.T) {
+	base := blob.New()
+	base.Append("level1", []byte("base level 1"))
+	base.Append("text", []byte("base text"))
+	dlc := blob.New()
+	dlc.Append("level2", []byte("dlc level 2"))
+	dlc.Append("text", []byte("dlc text"))
+	dlc.Append("text", []byte("second dlc text"))
+	var buf bytes.Buffer
+
+	err := blob.Merge(&buf, openBlob(t, base), openBlob(t, dlc))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	merged, err := blob.Read(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkBlobContent(t, merged,
+		"level1", "base level 1",
+		"text", "base text",
+		"level2", "dlc level 2",
+	)
+}
+
+func TestMergeWithLastWinsPolicy(t *testing.T) {
+	base := blob.New()
+	base.Append("level1", []byte("base level 1"))
+	base.Append("text", []byte("base text"))
+	dlc := blob.New()
+	dlc.Append("level2", []byte("dlc level 2"))
+	dlc.Append("text", []byte("dlc text"))
+	dlc.Append("text", []byte("second dlc text"))
+	var buf bytes.Buffer
+
+	err := blob.MergeWithPolicy(&buf, blob.LastWins, openBlob(t, base), openBlob(t, dlc))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	merged, err := blob.Read(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkBlobContent(t, merged,
+		"level1", "base level 1",
+		"text", "dlc text",
+		"level2", "dlc level 2",
+	)
+}
+
+func TestMergeWithErrorPolicy(t *testing.T) {
+	base := blob.New()
+	base.Append("level1", []byte("base level 1"))
+	base.Append("text", []byte("base text"))
+	dlc := blob.New()
+	dlc.Append("level2", []byte("dlc level 2"))
+	dlc.Append("text", []byte("dlc text"))
+	dlc.Append("text", []byte("second dlc text"))
+	var buf bytes.Buffer
+
+	err := blob.MergeWithPolicy(&buf, blob.ErrorOnConflict, openBlob(t, base), openBlob(t, dlc))
+
+	if err == nil {
+		t.Fatal("error expected for conflicting IDs")
+	}
+
+	// duplicates inside a single source are no conflict
+	buf.Reset()
+	err = blob.MergeWithPolicy(&buf, blob.ErrorOnConflict, openBlob(t, dlc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	merged, err := blob.Read(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkBlobContent(t, merged,
+		"level2", "dlc level 2",
+		"text", "dlc text",
+	)
+}
+
+func TestMergeNothingWritesEmptyBlob(t *testing.T) {
+	var buf bytes.Buffer
+	if err := blob.Merge(&buf); err != nil {
+		t.Fatal(err)
+	}
+	checkBytes(t, buf.Bytes(), []byte{0, 0, 0, 0})
+}
+
+func TestMergeWithInvalidPolicyFails(t *testing.T) {
+	var buf bytes.Buffer
+	if err := blob.MergeWithPolicy(&buf, blob.ConflictPolicy(-1)); err == nil {
+		t.Error("error expected")
+	}
+}
+
+func TestMergeFailsOnTruncatedSource(t *testing.T) {
+	b := blob.New()
+	b.Append("a", []byte("complete"))
+	b.Append("b", []byte("truncated"))
+	path := writeBlobFile(t, b)
+	src, err := blob.OpenPath(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer src.Close()
+	info, _ := os.Stat(path)
+	if err := os.Truncate(path, info.Size()-3); err != nil {
+		t.Fatal(err)
+	}
+
+	err = blob.Merge(&bytes.Buffer{}, src)
+
+	if err == nil || !strings.Contains(err.Error(), `"b"`) {
+		t.Error("want an error naming the truncated item but have", err)
+	}
+}
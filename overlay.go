@@ -0,0 +1,218 @@
+package blob
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"sync"
+)
+
+// WhiteoutPrefix marks whiteout entries. An entry with the ID
+// WhiteoutPrefix+id hides the ID id from all lower layers of an Overlay. The
+// data of a whiteout entry is ignored.
+const WhiteoutPrefix = ".wh."
+
+// Whiteout returns the ID of the whiteout entry that hides the given ID, see
+// WhiteoutPrefix.
+func Whiteout(id string) string {
+	return WhiteoutPrefix + id
+}
+
+// AppendWhiteout adds a whiteout entry for the given ID. When the blob is used
+// as a layer of an Overlay, it hides id in all layers below it.
+func (b *Blob) AppendWhiteout(id string) {
+	b.Append(Whiteout(id), nil)
+}
+
+// Overlay stacks several blobs on top of each other, like Doom loads PWADs on
+// top of its IWAD. Looking up an ID finds it in the topmost layer that has it,
+// so upper layers override lower ones without the need to rewrite them. An
+// upper layer can also hide an ID of the lower layers with a whiteout entry,
+// see WhiteoutPrefix.
+//
+// The item indices of an Overlay refer to its merged view of all layers. It
+// contains every visible ID once, in the order in which the IDs first appear,
+// going from the bottom layer to the top. Whiteout entries are not part of the
+// view.
+//
+// Readers returned for BlobReader layers are subject to the same restrictions
+// as described on Open. Handler takes care of this when serving an Overlay.
+//
+// An Overlay is safe for concurrent use, layers can be pushed while it is being
+// read, e.g. by a Handler.
+type Overlay struct {
+	mu     sync.RWMutex
+	layers []overlayLayer
+	view   []overlayItem
+	lookup map[string]int // ID to index in view
+}
+
+type overlayLayer struct {
+	header *header
+	open   func(i int) (ItemReader, error)
+	// serial is set if the layer's item readers share an io.ReadSeeker.
+	serial bool
+}
+
+type overlayItem struct {
+	id    string
+	layer int
+	index int
+}
+
+// NewOverlay creates an empty Overlay. Add layers with PushBlob and
+// PushBlobReader.
+func NewOverlay() *Overlay {
+	return &Overlay{}
+}
+
+// PushBlob adds b as the new top layer.
+func (o *Overlay) PushBlob(b *Blob) {
+	o.push(overlayLayer{
+		header: &b.header,
+		open: func(i int) (ItemReader, error) {
+			data, err := b.load(&b.items[i])
+			if err != nil {
+				return nil, err
+			}
+			return bytesItem{bytes.NewReader(data)}, nil
+		},
+	})
+}
+
+// PushBlobReader adds b as the new top layer.
+func (o *Overlay) PushBlobReader(b *BlobReader) {
+	o.push(overlayLayer{
+		header: &b.header,
+		open: func(i int) (ItemReader, error) {
+			return b.item(i), nil
+		},
+		serial: b.at == nil,
+	})
+}
+
+// LayerCount returns the number of layers in the Overlay.
+func (o *Overlay) LayerCount() int {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	return len(o.layers)
+}
+
+func (o *Overlay) push(layer overlayLayer) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.layers = append(o.layers, layer)
+	o.buildView()
+}
+
+// buildView merges all layers, from the bottom to the top, into one view. The
+// caller must hold o.mu for writing.
+func (o *Overlay) buildView() {
+	var all []overlayItem
+	hidden := make(map[int]bool) // indices into all
+	pos := make(map[string]int)  // ID to index in all
+	for l, layer := range o.layers {
+		// whiteouts only hide IDs of the layers below, so apply them first
+		for _, item := range layer.header.items {
+			if id, ok := strings.CutPrefix(item.id, WhiteoutPrefix); ok {
+				if p, ok := pos[id]; ok {
+					hidden[p] = true
+				}
+			}
+		}
+		for i, item := range layer.header.items {
+			if strings.HasPrefix(item.id, WhiteoutPrefix) {
+				continue
+			}
+			p, ok := pos[item.id]
+			if !ok {
+				pos[item.id] = len(all)
+				all = append(all, overlayItem{item.id, l, i})
+			} else if all[p].layer != l {
+				// only the first entry with an ID in a layer counts
+				all[p].layer, all[p].index = l, i
+				delete(hidden, p)
+			}
+		}
+	}
+
+	var view []overlayItem
+	lookup := make(map[string]int)
+	for p, item := range all {
+		if !hidden[p] {
+			lookup[item.id] = len(view)
+			view = append(view, item)
+		}
+	}
+	o.view, o.lookup = view, lookup
+}
+
+// ItemCount returns the number of visible items in the merged view of all
+// layers.
+func (o *Overlay) ItemCount() int {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	return len(o.view)
+}
+
+// GetIDAtIndex returns the ID of the entry at index i in the merged view or the
+// empty string if the given index is out of bounds.
+func (o *Overlay) GetIDAtIndex(i int) string {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	if i < 0 || i >= len(o.view) {
+		return ""
+	}
+	return o.view[i].id
+}
+
+// GetByID returns the data of the given ID from the topmost layer that has it.
+// If no layer has the ID or it is hidden by a whiteout entry, r will be nil and
+// found will be false. Like for Blob.GetByID, found is also false if the data
+// of an item added with AppendReader or AppendFile can not be read.
+func (o *Overlay) GetByID(id string) (r io.ReadSeeker, found bool) {
+	item, ok := o.find(id)
+	if !ok {
+		return nil, false
+	}
+	return o.get(item)
+}
+
+// GetByIndex returns the data of the entry at index i in the merged view. If
+// the index is out of bounds, r will be nil and found will be false.
+func (o *Overlay) GetByIndex(i int) (r io.ReadSeeker, found bool) {
+	o.mu.RLock()
+	if i < 0 || i >= len(o.view) {
+		o.mu.RUnlock()
+		return nil, false
+	}
+	item := o.view[i]
+	o.mu.RUnlock()
+	return o.get(item)
+}
+
+// find returns the entry of the merged view with the given ID.
+func (o *Overlay) find(id string) (overlayItem, bool) {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	i, ok := o.lookup[id]
+	if !ok {
+		return overlayItem{}, false
+	}
+	return o.view[i], true
+}
+
+func (o *Overlay) get(item overlayItem) (r io.ReadSeeker, found bool) {
+	data, err := o.open(item)
+	if err != nil {
+		return nil, false
+	}
+	return data, true
+}
+
+func (o *Overlay) open(item overlayItem) (ItemReader, error) {
+	o.mu.RLock()
+	open := o.layers[item.layer].open
+	o.mu.RUnlock()
+	return open(item.index)
+}
@@ -0,0 +1,112 @@
+package blob_test
+
+import (
+	"io"
+	"testing"
+
+	"github.com/gonutz/blob"
+)
+
+func checkOverlayItem(t *testing.T, o *blob.Overlay, id, want string) {
+	t.Helper()
+	r, found := o.GetByID(id)
+	if !found {
+		t.Fatal(id, "not found")
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != want {
+		t.Errorf("%s: want %q but have %q", id, want, data)
+	}
+}
+
+func TestOverlayResolvesFromTopmostLayer(t *testing.T) {
+	base := blob.New()
+	base.Append("MAP01", []byte("base map 1"))
+	base.Append("MAP02", []byte("base map 2"))
+	base.Append("SKY", []byte("base sky"))
+	mod := blob.New()
+	mod.Append("MAP02", []byte("mod map 2"))
+	mod.Append("MAP03", []byte("mod map 3"))
+
+	o := blob.NewOverlay()
+	o.PushBlobReader(openBlob(t, base))
+	o.PushBlob(mod)
+
+	if o.LayerCount() != 2 {
+		t.Error("want 2 layers but have", o.LayerCount())
+	}
+	checkOverlayItem(t, o, "MAP01", "base map 1")
+	checkOverlayItem(t, o, "MAP02", "mod map 2")
+	checkOverlayItem(t, o, "MAP03", "mod map 3")
+	if _, found := o.GetByID("MAP04"); found {
+		t.Error("MAP04 must not be found")
+	}
+
+	var ids []string
+	for i := 0; i < o.ItemCount(); i++ {
+		ids = append(ids, o.GetIDAtIndex(i))
+	}
+	checkStrings(t, ids, []string{"MAP01", "MAP02", "SKY", "MAP03"})
+
+	r, found := o.GetByIndex(1)
+	if !found {
+		t.Fatal("index 1 not found")
+	}
+	data, _ := io.ReadAll(r)
+	checkBytes(t, data, []byte("mod map 2"))
+	if _, found := o.GetByIndex(4); found {
+		t.Error("index 4 must not be found")
+	}
+	if id := o.GetIDAtIndex(-1); id != "" {
+		t.Error("want empty ID for invalid index but have", id)
+	}
+}
+
+func TestWhiteoutHidesLowerLayers(t *testing.T) {
+	base := blob.New()
+	base.Append("a", []byte("base a"))
+	base.Append("b", []byte("base b"))
+	base.Append("c", []byte("base c"))
+	mod := blob.New()
+	mod.AppendWhiteout("a")
+	mod.Append("b", []byte("mod b"))
+	mod.AppendWhiteout("b") // does not hide its own layer's b
+	top := blob.New()
+	top.Append("a", []byte("top a"))
+	top.AppendWhiteout("c")
+
+	o := blob.NewOverlay()
+	o.PushBlob(base)
+	o.PushBlob(mod)
+
+	if _, found := o.GetByID("a"); found {
+		t.Error("a must be hidden")
+	}
+	checkOverlayItem(t, o, "b", "mod b")
+	var ids []string
+	for i := 0; i < o.ItemCount(); i++ {
+		ids = append(ids, o.GetIDAtIndex(i))
+	}
+	checkStrings(t, ids, []string{"b", "c"})
+
+	o.PushBlob(top)
+
+	checkOverlayItem(t, o, "a", "top a")
+	if _, found := o.GetByID("c"); found {
+		t.Error("c must be hidden")
+	}
+	ids = nil
+	for i := 0; i < o.ItemCount(); i++ {
+		ids = append(ids, o.GetIDAtIndex(i))
+	}
+	checkStrings(t, ids, []string{"a", "b"})
+}
+
+func TestWhiteoutID(t *testing.T) {
+	if id := blob.Whiteout("maps/e1m1"); id != ".wh.maps/e1m1" {
+		t.Error("whiteout ID was", id)
+	}
+}
@@ -0,0 +1,438 @@
+package blob
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"hash"
+	"io"
+	"math"
+	"strconv"
+)
+
+// A patch describes how to turn an old blob into a new one. It is laid out as
+// follows, all numbers are encoded in little endian byte order:
+//
+//	[8]byte:  "BLOBDIFF"
+//	[32]byte: SHA-256 checksum of the old blob, see checksum
+//	[32]byte: SHA-256 checksum of the new blob
+//	uint8:    1 if the new blob's header is sorted, 0 otherwise
+//	uint32:   item count of the new blob
+//	loop, one entry per item of the new blob {
+//	  uint16: ID length in bytes, length of the following ID
+//	  string: ID, UTF-8 encoded
+//	  uint64: data length in bytes
+//	  uint8:  operation, patchKeep, patchAdd or patchDelta
+//	  uint32: index of the item in the old blob, only for patchKeep and
+//	          patchDelta
+//	}
+//	loop, one entry per item with operation patchAdd or patchDelta {
+//	  patchAdd:   the item data
+//	  patchDelta: uint64 length of the delta, followed by the delta, see
+//	              computeDelta
+//	}
+//
+// Items of the old blob that are not referenced are removed.
+const patchMagic = "BLOBDIFF"
+
+const (
+	// patchKeep copies an item from the old blob unchanged.
+	patchKeep = iota
+	// patchAdd stores the whole item data in the patch.
+	patchAdd
+	// patchDelta stores the changes to an item of the old blob.
+	patchDelta
+)
+
+// Delta operations, each followed by uvarints.
+const (
+	// deltaCopy copies bytes from the old item, followed by offset and length.
+	deltaCopy = iota
+	// deltaInsert inserts bytes from the delta, followed by the length and the
+	// bytes.
+	deltaInsert
+)
+
+// deltaBlockSize is the length of the blocks in the old data that computeDelta
+// looks for in the new data.
+const deltaBlockSize = 32
+
+// Diff writes a patch to w that turns the blob old into the blob new. Items are
+// matched by ID, unchanged items are only referenced, new items are stored as
+// a whole and changed items are stored as binary deltas against their old
+// version. Use Patch to apply it.
+//
+// Diff reads both blobs completely to compute their checksums. Matching items
+// are loaded into memory one pair at a time to compare them. Changed items are
+// loaded a second time to write their deltas, so that the deltas are not kept
+// in memory either.
+func Diff(old, new *BlobReader, w io.Writer) error {
+	oldSum, err := checksum(old)
+	if err != nil {
+		return errors.New("blob.Diff: old blob: " + err.Error())
+	}
+	newSum, err := checksum(new)
+	if err != nil {
+		return errors.New("blob.Diff: new blob: " + err.Error())
+	}
+
+	table := bytes.NewBuffer(nil)
+	table.WriteString(patchMagic)
+	table.Write(oldSum[:])
+	table.Write(newSum[:])
+	if new.sorted {
+		table.WriteByte(1)
+	} else {
+		table.WriteByte(0)
+	}
+	binary.Write(table, byteOrder, uint32(len(new.items)))
+
+	// the operations are decided first, the payloads that follow the table are
+	// only written afterwards, so neither added data nor deltas are kept in
+	// memory; deltas are computed again when writing them
+	type diffItem struct {
+		op       byte
+		oldIndex int
+	}
+	ops := make([]diffItem, len(new.items))
+	for i, item := range new.items {
+		op := diffItem{op: patchAdd}
+		if oldIndex, found := old.find(item.id); found {
+			oldData, data, err := readItemPair(old, oldIndex, new, i)
+			if err != nil {
+				return errors.New("blob.Diff: " + err.Error())
+			}
+			if bytes.Equal(oldData, data) {
+				op = diffItem{patchKeep, oldIndex}
+			} else if len(computeDelta(oldData, data)) < len(data) {
+				op = diffItem{patchDelta, oldIndex}
+			}
+		}
+		ops[i] = op
+
+		binary.Write(table, byteOrder, uint16(len(item.id)))
+		table.WriteString(item.id)
+		binary.Write(table, byteOrder, item.end-item.start)
+		table.WriteByte(op.op)
+		if op.op != patchAdd {
+			binary.Write(table, byteOrder, uint32(op.oldIndex))
+		}
+	}
+
+	if _, err := w.Write(table.Bytes()); err != nil {
+		return errors.New("blob.Diff: " + err.Error())
+	}
+	for i, op := range ops {
+		var err error
+		switch op.op {
+		case patchAdd:
+			err = new.copyItem(w, i)
+		case patchDelta:
+			var oldData, data []byte
+			oldData, data, err = readItemPair(old, op.oldIndex, new, i)
+			if err == nil {
+				delta := computeDelta(oldData, data)
+				err = binary.Write(w, byteOrder, uint64(len(delta)))
+				if err == nil {
+					_, err = w.Write(delta)
+				}
+			}
+		}
+		if err != nil {
+			return errors.New("blob.Diff: " + err.Error())
+		}
+	}
+	return nil
+}
+
+// Patch applies a patch that was created with Diff to the blob old and writes
+// the resulting blob to out. It fails before writing anything if old is not
+// the blob that the patch was created for. After writing, the result is
+// verified against the checksum stored in the patch.
+//
+// Unchanged items are streamed from old, old is not loaded into memory.
+func Patch(old *BlobReader, patch io.Reader, out io.Writer) error {
+	p := bufio.NewReader(patch)
+
+	var start [len(patchMagic) + 2*sha256.Size + 1]byte
+	if _, err := io.ReadFull(p, start[:]); err != nil {
+		return errors.New("blob.Patch: read patch header: " + err.Error())
+	}
+	if string(start[:len(patchMagic)]) != patchMagic {
+		return errors.New("blob.Patch: not a blob patch")
+	}
+	var wantOld, wantNew [sha256.Size]byte
+	copy(wantOld[:], start[len(patchMagic):])
+	copy(wantNew[:], start[len(patchMagic)+sha256.Size:])
+	sorted := start[len(start)-1] == 1
+
+	oldSum, err := checksum(old)
+	if err != nil {
+		return errors.New("blob.Patch: old blob: " + err.Error())
+	}
+	if oldSum != wantOld {
+		return errors.New("blob.Patch: the patch was made for a different blob")
+	}
+
+	type patchItem struct {
+		op       byte
+		oldIndex int
+	}
+	var itemCount uint32
+	if err := binary.Read(p, byteOrder, &itemCount); err != nil {
+		return errors.New("blob.Patch: read item count: " + err.Error())
+	}
+	var items []indexItem
+	var ops []patchItem
+	var offset uint64
+	for range itemCount {
+		var idLength uint16
+		if err := binary.Read(p, byteOrder, &idLength); err != nil {
+			return errors.New("blob.Patch: read ID length: " + err.Error())
+		}
+		id := make([]byte, idLength)
+		if _, err := io.ReadFull(p, id); err != nil {
+			return errors.New("blob.Patch: read ID: " + err.Error())
+		}
+		var size uint64
+		if err := binary.Read(p, byteOrder, &size); err != nil {
+			return errors.New("blob.Patch: read size: " + err.Error())
+		}
+		op, err := p.ReadByte()
+		if err != nil {
+			return errors.New("blob.Patch: read operation: " + err.Error())
+		}
+		if offset+size < offset || offset+size > math.MaxInt64 {
+			return errors.New("blob.Patch: item is too large")
+		}
+		item := patchItem{op: op}
+		if op == patchKeep || op == patchDelta {
+			var oldIndex uint32
+			if err := binary.Read(p, byteOrder, &oldIndex); err != nil {
+				return errors.New("blob.Patch: read old index: " + err.Error())
+			}
+			if int64(oldIndex) >= int64(len(old.items)) {
+				return errors.New("blob.Patch: old index out of bounds")
+			}
+			item.oldIndex = int(oldIndex)
+		} else if op != patchAdd {
+			return errors.New("blob.Patch: unknown operation " + strconv.Itoa(int(op)))
+		}
+		items = append(items, indexItem{id: string(id), start: offset, end: offset + size})
+		ops = append(ops, item)
+		offset += size
+	}
+
+	newSum := sha256.New()
+	w := io.MultiWriter(out, newSum)
+	headerData, err := encodeHeader(items, sorted)
+	if err != nil {
+		return errors.New("blob.Patch: " + err.Error())
+	}
+	if _, err := w.Write(headerData); err != nil {
+		return errors.New("blob.Patch: write header: " + err.Error())
+	}
+	for i, item := range items {
+		size := int64(item.end - item.start)
+		var err error
+		switch ops[i].op {
+		case patchKeep:
+			if old.items[ops[i].oldIndex].end-old.items[ops[i].oldIndex].start != uint64(size) {
+				err = errors.New("size of kept item changed")
+			} else {
+				err = old.copyItem(w, ops[i].oldIndex)
+			}
+		case patchAdd:
+			_, err = io.CopyN(w, p, size)
+		case patchDelta:
+			err = applyDelta(w, old.item(ops[i].oldIndex), p, size)
+		}
+		if err != nil {
+			return errors.New("blob.Patch: item " + strconv.Quote(item.id) + ": " + err.Error())
+		}
+	}
+
+	if !bytes.Equal(newSum.Sum(nil), wantNew[:]) {
+		return errors.New("blob.Patch: the result does not match the checksum in the patch")
+	}
+	return nil
+}
+
+// checksum computes the SHA-256 checksum of the header and all data of b. For
+// a blob read from a file, this is the checksum of the file.
+func checksum(b *BlobReader) ([sha256.Size]byte, error) {
+	var sum [sha256.Size]byte
+	h := sha256.New()
+	if err := hashBlob(h, b); err != nil {
+		return sum, err
+	}
+	copy(sum[:], h.Sum(nil))
+	return sum, nil
+}
+
+func hashBlob(h hash.Hash, b *BlobReader) error {
+	headerData, err := encodeHeader(b.items, b.sorted)
+	if err != nil {
+		return err
+	}
+	h.Write(headerData)
+	for i := range b.items {
+		if err := b.copyItem(h, i); err != nil {
+			return errors.New("read " + strconv.Quote(b.items[i].id) + ": " + err.Error())
+		}
+	}
+	return nil
+}
+
+// readItem loads the data of the entry at the valid index i into memory.
+func readItem(b *BlobReader, i int) ([]byte, error) {
+	data := make([]byte, b.items[i].end-b.items[i].start)
+	if _, err := io.ReadFull(b.item(i), data); err != nil {
+		return nil, errors.New("read " + strconv.Quote(b.items[i].id) + ": " + err.Error())
+	}
+	return data, nil
+}
+
+// readItemPair loads the data of the entry at index oldIndex in old and of the
+// entry at index newIndex in new into memory.
+func readItemPair(old *BlobReader, oldIndex int, new *BlobReader, newIndex int) (oldData, newData []byte, err error) {
+	oldData, err = readItem(old, oldIndex)
+	if err != nil {
+		return nil, nil, err
+	}
+	newData, err = readItem(new, newIndex)
+	if err != nil {
+		return nil, nil, err
+	}
+	return oldData, newData, nil
+}
+
+// computeDelta returns a list of operations that create new from old. It
+// indexes all blocks of deltaBlockSize bytes in old and then looks for them at
+// every position in new, using a rolling hash. Found blocks are extended as far
+// as possible and copied from old, everything else is inserted literally.
+//
+// The delta is a sequence of deltaCopy and deltaInsert operations.
+func computeDelta(old, new []byte) []byte {
+	const base = 257
+	// pow is base^deltaBlockSize, used to roll the first byte out of the hash
+	var pow uint64 = 1
+	for range deltaBlockSize {
+		pow *= base
+	}
+	blockHash := func(b []byte) uint64 {
+		var h uint64
+		for _, c := range b {
+			h = h*base + uint64(c)
+		}
+		return h
+	}
+
+	blocks := make(map[uint64]int)
+	for i := 0; i+deltaBlockSize <= len(old); i += deltaBlockSize {
+		h := blockHash(old[i : i+deltaBlockSize])
+		if _, ok := blocks[h]; !ok {
+			blocks[h] = i
+		}
+	}
+
+	var delta []byte
+	insert := func(data []byte) {
+		if len(data) > 0 {
+			delta = append(delta, deltaInsert)
+			delta = binary.AppendUvarint(delta, uint64(len(data)))
+			delta = append(delta, data...)
+		}
+	}
+
+	literal := 0 // start of the data that is not yet in delta
+	i := 0
+	var h uint64
+	if len(new) >= deltaBlockSize {
+		h = blockHash(new[:deltaBlockSize])
+	}
+	for i+deltaBlockSize <= len(new) {
+		if off, ok := blocks[h]; ok && bytes.Equal(old[off:off+deltaBlockSize], new[i:i+deltaBlockSize]) {
+			n := deltaBlockSize
+			for i+n < len(new) && off+n < len(old) && new[i+n] == old[off+n] {
+				n++
+			}
+			insert(new[literal:i])
+			delta = append(delta, deltaCopy)
+			delta = binary.AppendUvarint(delta, uint64(off))
+			delta = binary.AppendUvarint(delta, uint64(n))
+			i += n
+			literal = i
+			if i+deltaBlockSize <= len(new) {
+				h = blockHash(new[i : i+deltaBlockSize])
+			}
+			continue
+		}
+		if i+deltaBlockSize < len(new) {
+			h = h*base + uint64(new[i+deltaBlockSize]) - pow*uint64(new[i])
+		}
+		i++
+	}
+	insert(new[literal:])
+	return delta
+}
+
+// applyDelta reads a delta of the form written by Diff from p and writes the
+// resulting size bytes to w, copying from old as the delta says.
+func applyDelta(w io.Writer, old io.ReadSeeker, p *bufio.Reader, size int64) error {
+	var deltaLength uint64
+	if err := binary.Read(p, byteOrder, &deltaLength); err != nil {
+		return errors.New("read delta length: " + err.Error())
+	}
+	delta := bufio.NewReader(io.LimitReader(p, int64(deltaLength)))
+	var written int64
+	for {
+		op, err := delta.ReadByte()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		switch op {
+		case deltaCopy:
+			offset, err := binary.ReadUvarint(delta)
+			if err != nil {
+				return err
+			}
+			length, err := binary.ReadUvarint(delta)
+			if err != nil {
+				return err
+			}
+			if _, err := old.Seek(int64(offset), io.SeekStart); err != nil {
+				return err
+			}
+			n, err := io.CopyN(w, old, int64(length))
+			written += n
+			if err != nil {
+				return err
+			}
+		case deltaInsert:
+			length, err := binary.ReadUvarint(delta)
+			if err != nil {
+				return err
+			}
+			n, err := io.CopyN(w, delta, int64(length))
+			written += n
+			if err != nil {
+				return err
+			}
+		default:
+			return errors.New("unknown delta operation " + strconv.Itoa(int(op)))
+		}
+		if written > size {
+			return errors.New("delta is longer than the item")
+		}
+	}
+	if written != size {
+		return errors.New("delta is shorter than the item")
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package blob_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/gonutz/blob"
+)
+
+func TestDiffAndPatchRecreateNewBlob(t *testing.T) {
+	level := bytes.Repeat([]byte("0123456789abcdefghijklmnopqrstuvwxyz"), 100)
+	changedLevel := append([]byte("new start "), level[:1500]...)
+	changedLevel = append(changedLevel, []byte(" new middle ")...)
+	changedLevel = append(changedLevel, level[1500:]...)
+	o := blob.New()
+	o.Append("level", level)
+	o.Append("removed", []byte("this is gone"))
+	o.Append("same", []byte("unchanged"))
+	o.Append("small", []byte("abc"))
+	n := blob.New()
+	n.Append("same", []byte("unchanged"))
+	n.Append("added", []byte("this is new"))
+	n.Append("level", changedLevel)
+	n.Append("small", []byte("xyz"))
+	old, new := openBlob(t, o), openBlob(t, n)
+	var patch bytes.Buffer
+	if err := blob.Diff(old, new, &patch); err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+
+	err := blob.Patch(old, &patch, &out)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkBytes(t, out.Bytes(), encodeBlob(t, new))
+}
+
+// encodeBlob returns the blob encoding of b.
+func encodeBlob(t *testing.T, b *blob.BlobReader) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := blob.Merge(&buf, b); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestPatchIsSmallerThanChangedData(t *testing.T) {
+	level := bytes.Repeat([]byte("0123456789abcdefghijklmnopqrstuvwxyz"), 100)
+	o := blob.New()
+	o.Append("level", level)
+	changedLevel := append([]byte("new start "), level...)
+	n := blob.New()
+	n.Append("level", changedLevel)
+	var patch bytes.Buffer
+
+	err := blob.Diff(openBlob(t, o), openBlob(t, n), &patch)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	if patch.Len() >= len(changedLevel) {
+		t.Errorf("patch has %d bytes, changed level has %d", patch.Len(), len(changedLevel))
+	}
+}
+
+func TestPatchChecksOldBlob(t *testing.T) {
+	o := blob.New()
+	o.Append("a", []byte("old"))
+	n := blob.New()
+	n.Append("a", []byte("new"))
+	old, new := openBlob(t, o), openBlob(t, n)
+	var patch bytes.Buffer
+	blob.Diff(old, new, &patch)
+
+	var out bytes.Buffer
+	err := blob.Patch(new, &patch, &out)
+
+	if err == nil || !strings.Contains(err.Error(), "different blob") {
+		t.Error("want error about wrong blob but got", err)
+	}
+	if out.Len() != 0 {
+		t.Error("nothing should be written for the wrong blob")
+	}
+}
+
+func TestPatchDetectsCorruption(t *testing.T) {
+	o := blob.New()
+	o.Append("a", []byte("old"))
+	n := blob.New()
+	n.Append("a", []byte("new"))
+	n.Append("b", []byte("added"))
+	old, new := openBlob(t, o), openBlob(t, n)
+	var patch bytes.Buffer
+	blob.Diff(old, new, &patch)
+	data := patch.Bytes()
+	data[len(data)-1]++
+
+	var out bytes.Buffer
+	if err := blob.Patch(old, bytes.NewReader(data), &out); err == nil {
+		t.Error("error expected for corrupted patch")
+	}
+
+	if err := blob.Patch(old, strings.NewReader("not a patch"), &out); err == nil {
+		t.Error("error expected for invalid patch")
+	}
+}
@@ -0,0 +1,187 @@
+package blob
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// ProgressFunc is called while a blob is written or read. done is the number
+// of bytes written or read so far, total is the size of the whole blob, both
+// include the header. id is the ID of the item whose data is being processed,
+// it is empty while the header, or the free space of a blob changed with Edit,
+// is processed.
+type ProgressFunc func(done, total int64, id string)
+
+// trackChunkSize is the maximum number of bytes written or read at once when
+// reporting progress or checking for cancellation, so that large items report
+// progress and can be canceled in between. Writes and reads are also split at
+// item boundaries.
+const trackChunkSize = 1 << 20
+
+// WriteWithProgress writes the blob like Write and calls progress while doing
+// so, see ProgressFunc.
+func (b *Blob) WriteWithProgress(w io.Writer, progress ProgressFunc) error {
+	return b.WriteWithOptions(w, WriteOptions{Progress: progress})
+}
+
+// ReadWithProgress reads a blob like Read and calls progress while doing so,
+// see ProgressFunc.
+func ReadWithProgress(r io.Reader, progress ProgressFunc) (*Blob, error) {
+	return ReadWithOptions(r, ReadOptions{Progress: progress})
+}
+
+// tracker keeps track of the bytes done while writing or reading a blob. It
+// reports the progress and stops when its context is canceled.
+type tracker struct {
+	ctx       context.Context
+	report    ProgressFunc
+	done      int64
+	total     int64
+	dataStart int64
+	// ids and ends hold the items' IDs and the offsets where their data ends,
+	// relative to dataStart, in the order in which they are processed.
+	ids  []string
+	ends []int64
+	// gaps marks the entries that are not items but other parts of the
+	// blob, like the free space and the index of a blob changed with Edit. It
+	// is nil if there are none.
+	gaps []bool
+}
+
+// newTracker prepares to track a blob with the given header size, processing
+// the given items in order. It returns nil if there is nothing to track, i.e.
+// if report is nil and ctx can not be canceled.
+func newTracker(ctx context.Context, report ProgressFunc, headerSize int64, items []indexItem) *tracker {
+	if report == nil && ctx.Done() == nil {
+		return nil
+	}
+	t := &tracker{
+		ctx:       ctx,
+		report:    report,
+		dataStart: headerSize,
+		ids:       make([]string, len(items)),
+		ends:      make([]int64, len(items)),
+	}
+	var end int64
+	for i := range items {
+		end += int64(items[i].end - items[i].start)
+		t.ids[i] = items[i].id
+		t.ends[i] = end
+	}
+	t.total = headerSize + end
+	return t
+}
+
+// item returns the index of the item that contains the data byte at pos,
+// which is relative to dataStart, or the index after the last item. If
+// inclusive is true, the item that ends at pos is returned instead.
+func (t *tracker) item(pos int64, inclusive bool) int {
+	return sort.Search(len(t.ends), func(i int) bool {
+		return t.ends[i] > pos || inclusive && t.ends[i] == pos
+	})
+}
+
+// next returns how many of n bytes to process next so that at most one item,
+// or only the header, is part of it.
+func (t *tracker) next(n int) int {
+	n = min(n, trackChunkSize)
+	pos := t.done - t.dataStart
+	if pos < 0 {
+		return int(min(int64(n), -pos))
+	}
+	if i := t.item(pos, false); i < len(t.ends) {
+		n = int(min(int64(n), t.ends[i]-pos))
+	}
+	return n
+}
+
+// check returns an error naming the part of the blob that is processed next
+// if the context was canceled.
+func (t *tracker) check() error {
+	err := t.ctx.Err()
+	if err == nil {
+		return nil
+	}
+	pos := t.done - t.dataStart
+	if pos < 0 {
+		return fmt.Errorf("blob: header: %w", err)
+	}
+	if i := t.item(pos, false); i < len(t.ids) {
+		if t.gaps != nil && t.gaps[i] {
+			return fmt.Errorf("blob: offset %d: %w", t.done, err)
+		}
+		return itemError("blob", t.ids[i], err)
+	}
+	return err
+}
+
+func (t *tracker) add(n int) {
+	if n == 0 {
+		return
+	}
+	t.done += int64(n)
+	if t.report == nil {
+		return
+	}
+	var id string
+	if pos := t.done - t.dataStart; pos > 0 {
+		// the item that contains the last byte done
+		if i := t.item(pos, true); i < len(t.ids) && (t.gaps == nil || !t.gaps[i]) {
+			id = t.ids[i]
+		}
+	}
+	t.report(t.done, t.total, id)
+}
+
+type trackingWriter struct {
+	w io.Writer
+	t *tracker
+}
+
+func (w *trackingWriter) Write(p []byte) (int, error) {
+	var n int
+	for len(p) > 0 {
+		if err := w.t.check(); err != nil {
+			return n, err
+		}
+		chunk := p[:w.t.next(len(p))]
+		m, err := w.w.Write(chunk)
+		n += m
+		w.t.add(m)
+		if err != nil {
+			return n, err
+		}
+		p = p[m:]
+	}
+	return n, nil
+}
+
+type trackingReader struct {
+	r io.Reader
+	t *tracker
+}
+
+func (r *trackingReader) Read(p []byte) (int, error) {
+	if err := r.t.check(); err != nil {
+		return 0, err
+	}
+	p = p[:r.t.next(len(p))]
+	n, err := r.r.Read(p)
+	r.t.add(n)
+	return n, err
+}
+
+// contextReader stops reading from r when ctx is canceled.
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r *contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p[:min(len(p), trackChunkSize)])
+}
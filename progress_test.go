@@ -0,0 +1,100 @@
+package blob_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/gonutz/blob"
+)
+
+type progressCall struct {
+	done, total int64
+	id          string
+}
+
+func checkProgress(t *testing.T, calls []progressCall, total int64, ids ...string) {
+	t.Helper()
+	if len(calls) == 0 {
+		t.Fatal("no progress reported")
+	}
+	var last int64
+	for _, c := range calls {
+		if c.total != total {
+			t.Error("want total", total, "but have", c.total)
+		}
+		if c.done <= last {
+			t.Error("done must increase but went from", last, "to", c.done)
+		}
+		last = c.done
+	}
+	if last != total {
+		t.Error("want final done", total, "but have", last)
+	}
+	var have []string
+	for _, c := range calls {
+		if len(have) == 0 || have[len(have)-1] != c.id {
+			have = append(have, c.id)
+		}
+	}
+	checkStrings(t, have, ids)
+}
+
+func TestWriteWithProgress(t *testing.T) {
+	b := blob.New()
+	b.Append("b", []byte("bb"))
+	b.Append("empty", nil)
+	b.Append("a", bytes.Repeat([]byte{1}, 3<<20))
+	var calls []progressCall
+
+	var buf bytes.Buffer
+	err := b.WriteWithProgress(&buf, func(done, total int64, id string) {
+		calls = append(calls, progressCall{done, total, id})
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkProgress(t, calls, b.Size(), "", "b", "a")
+	if len(calls) < 4 {
+		t.Error("large items must report progress in between, calls:", len(calls))
+	}
+}
+
+func TestWriteWithProgressUsesSortedOrder(t *testing.T) {
+	b := blob.New()
+	b.Append("b", []byte("bb"))
+	b.Append("empty", nil)
+	b.Append("a", bytes.Repeat([]byte{1}, 3<<20))
+	var calls []progressCall
+
+	var buf bytes.Buffer
+	err := b.WriteWithOptions(&buf, blob.WriteOptions{
+		SortIDs: true,
+		Progress: func(done, total int64, id string) {
+			calls = append(calls, progressCall{done, total, id})
+		},
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkProgress(t, calls, b.Size(), "", "a", "b")
+}
+
+func TestReadWithProgress(t *testing.T) {
+	b := blob.New()
+	b.Append("b", []byte("bb"))
+	b.Append("empty", nil)
+	b.Append("a", bytes.Repeat([]byte{1}, 3<<20))
+	data, _ := b.MarshalBinary()
+	var calls []progressCall
+
+	_, err := blob.ReadWithProgress(bytes.NewReader(data), func(done, total int64, id string) {
+		calls = append(calls, progressCall{done, total, id})
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkProgress(t, calls, int64(len(data)), "", "b", "a")
+}
@@ -0,0 +1,140 @@
+package blob
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+)
+
+// ErrRemoteChanged is returned when reading from a blob opened with OpenHTTP
+// after the file on the server has changed.
+var ErrRemoteChanged = errors.New("blob: remote blob has changed")
+
+// httpReadAhead is the minimum number of bytes that are requested at once.
+// Small reads that follow each other, like reading the header or a couple of
+// small neighboring items, are thus served by a single request.
+const httpReadAhead = 64 << 10
+
+// OpenHTTP opens a blob file that is served at the given URL, fetching only
+// the parts that are actually read. The server must support HTTP Range
+// requests. The header is fetched right away, item data only when it is read
+// from an item reader. Small reads of adjacent data are coalesced into one
+// request.
+//
+// The ETag of the first response is compared with that of every following
+// response. If the file on the server changes, reads fail with
+// ErrRemoteChanged. The ETags are compared as they are, so weak ETags work as
+// well.
+//
+// If client is nil, http.DefaultClient is used. See Open for the restrictions
+// on reading from the returned BlobReader.
+func OpenHTTP(url string, client *http.Client) (*BlobReader, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	r := &httpReadSeeker{url: url, client: client}
+	if err := r.fetch(0, httpReadAhead); err != nil {
+		return nil, errors.New("open remote blob: " + err.Error())
+	}
+	return Open(r)
+}
+
+// httpReadSeeker reads a file from an HTTP server with Range requests.
+type httpReadSeeker struct {
+	url    string
+	client *http.Client
+	etag   string
+	size   int64
+	pos    int64
+	// buf holds the data that was fetched last, starting at offset bufStart.
+	buf      []byte
+	bufStart int64
+}
+
+func (r *httpReadSeeker) Read(p []byte) (int, error) {
+	if r.pos >= r.size {
+		return 0, io.EOF
+	}
+	if r.pos < r.bufStart || r.pos >= r.bufStart+int64(len(r.buf)) {
+		if err := r.fetch(r.pos, max(int64(len(p)), httpReadAhead)); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, r.buf[r.pos-r.bufStart:])
+	r.pos += int64(n)
+	return n, nil
+}
+
+func (r *httpReadSeeker) Seek(offset int64, whence int) (int64, error) {
+	var pos int64
+	switch whence {
+	case io.SeekStart:
+		pos = offset
+	case io.SeekCurrent:
+		pos = r.pos + offset
+	case io.SeekEnd:
+		pos = r.size + offset
+	default:
+		return r.pos, errors.New("blob.httpReadSeeker.Seek: invalid whence")
+	}
+	if pos < 0 {
+		return r.pos, errors.New("blob.httpReadSeeker.Seek: negative position")
+	}
+	r.pos = pos
+	return pos, nil
+}
+
+// fetch requests n bytes starting at offset, or less at the end of the file,
+// and keeps them in buf. The first call also determines the file's size and
+// ETag.
+func (r *httpReadSeeker) fetch(offset, n int64) error {
+	first := r.buf == nil
+	if !first {
+		n = min(n, r.size-offset)
+	}
+
+	req, err := http.NewRequest(http.MethodGet, r.url, nil)
+	if err != nil {
+		return err
+	}
+	// If-Match would make the server compare weak ETags strongly and always
+	// fail, so the ETag of the response is compared instead
+	req.Header.Set("Range", "bytes="+strconv.FormatInt(offset, 10)+"-"+strconv.FormatInt(offset+n-1, 10))
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusPartialContent {
+		return errors.New("range request returned " + resp.Status)
+	}
+	if etag := resp.Header.Get("ETag"); !first && etag != r.etag {
+		return ErrRemoteChanged
+	}
+
+	var start, end, size int64
+	_, err = fmt.Sscanf(resp.Header.Get("Content-Range"), "bytes %d-%d/%d", &start, &end, &size)
+	if err != nil {
+		return errors.New("invalid Content-Range: " + err.Error())
+	}
+	if start != offset || end < start || end-start >= n || size <= end {
+		return errors.New("response does not match the requested range")
+	}
+	if first {
+		r.size = size
+		r.etag = resp.Header.Get("ETag")
+	} else if size != r.size {
+		return ErrRemoteChanged
+	}
+
+	buf := make([]byte, end-start+1)
+	if _, err := io.ReadFull(resp.Body, buf); err != nil {
+		return err
+	}
+	r.buf = buf
+	r.bufStart = offset
+	return nil
+}
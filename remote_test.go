@@ -0,0 +1,168 @@
+package blob_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gonutz/blob"
+)
+
+// blobServer serves a blob file with support for Range requests and ETags.
+type blobServer struct {
+	mu       sync.Mutex
+	data     []byte
+	etag     string
+	requests int
+}
+
+func (s *blobServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	data, etag := s.data, s.etag
+	s.requests++
+	s.mu.Unlock()
+	w.Header().Set("ETag", etag)
+	http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(data))
+}
+
+func (s *blobServer) set(data []byte, etag string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data, s.etag = data, etag
+}
+
+func (s *blobServer) requestCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests
+}
+
+func readRemote(t *testing.T, b *blob.BlobReader, id string) ([]byte, error) {
+	t.Helper()
+	r, found := b.GetByID(id)
+	if !found {
+		t.Fatal(id, "not found")
+	}
+	return io.ReadAll(r)
+}
+
+func TestOpenHTTPReadsItemsOnDemand(t *testing.T) {
+	served := blob.New()
+	served.Append("small1", []byte("first"))
+	served.Append("small2", []byte("second"))
+	served.Append("large", bytes.Repeat([]byte{7}, 200<<10))
+	served.Append("after", []byte("after large"))
+	file, _ := served.MarshalBinary()
+	s := &blobServer{}
+	s.set(file, `"v1"`)
+	server := httptest.NewServer(s)
+	defer server.Close()
+
+	b, err := blob.OpenHTTP(server.URL, server.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.ItemCount() != 4 {
+		t.Fatal("want 4 items but have", b.ItemCount())
+	}
+
+	data, err := readRemote(t, b, "small1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkBytes(t, data, []byte("first"))
+	data, err = readRemote(t, b, "small2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkBytes(t, data, []byte("second"))
+	if n := s.requestCount(); n != 1 {
+		t.Error("header and small items should come in 1 request but took", n)
+	}
+
+	data, err = readRemote(t, b, "after")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkBytes(t, data, []byte("after large"))
+	if n := s.requestCount(); n != 2 {
+		t.Error("want 2 requests but have", n)
+	}
+
+	data, err = readRemote(t, b, "large")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkBytes(t, data, bytes.Repeat([]byte{7}, 200<<10))
+}
+
+func TestOpenHTTPDetectsChanges(t *testing.T) {
+	served := blob.New()
+	served.Append("small1", []byte("first"))
+	served.Append("small2", []byte("second"))
+	served.Append("large", bytes.Repeat([]byte{7}, 200<<10))
+	served.Append("after", []byte("after large"))
+	file, _ := served.MarshalBinary()
+	s := &blobServer{}
+	s.set(file, `"v1"`)
+	server := httptest.NewServer(s)
+	defer server.Close()
+
+	b, err := blob.OpenHTTP(server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.set(file, `"v2"`)
+
+	_, err = readRemote(t, b, "after")
+	if !errors.Is(err, blob.ErrRemoteChanged) {
+		t.Error("want ErrRemoteChanged but got", err)
+	}
+}
+
+func TestOpenHTTPWorksWithWeakETags(t *testing.T) {
+	served := blob.New()
+	served.Append("small1", []byte("first"))
+	served.Append("small2", []byte("second"))
+	served.Append("large", bytes.Repeat([]byte{7}, 200<<10))
+	served.Append("after", []byte("after large"))
+	file, _ := served.MarshalBinary()
+	s := &blobServer{}
+	s.set(file, `W/"v1"`)
+	server := httptest.NewServer(s)
+	defer server.Close()
+
+	b, err := blob.OpenHTTP(server.URL, server.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := readRemote(t, b, "large")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkBytes(t, data, bytes.Repeat([]byte{7}, 200<<10))
+
+	s.set(file, `W/"v2"`)
+	if _, err := readRemote(t, b, "small1"); !errors.Is(err, blob.ErrRemoteChanged) {
+		t.Error("want ErrRemoteChanged but got", err)
+	}
+}
+
+func TestOpenHTTPFailsWithoutRangeSupport(t *testing.T) {
+	served := blob.New()
+	served.Append("a", []byte("data"))
+	file, _ := served.MarshalBinary()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(file)
+	}))
+	defer server.Close()
+
+	if _, err := blob.OpenHTTP(server.URL, nil); err == nil {
+		t.Error("error expected")
+	}
+}
@@ -0,0 +1,136 @@
+package blob
+
+import (
+	"errors"
+	"io"
+	"strings"
+)
+
+// Scanner reads a blob item by item from a stream that can not seek, like a
+// pipe or a network connection. Unlike Read, it does not keep the data in
+// memory, and unlike Open, it does not need an io.ReadSeeker.
+//
+// Example:
+//
+//	s := blob.NewScanner(conn)
+//	for s.Next() {
+//		fmt.Println(s.ID(), s.Size())
+//		io.Copy(dst, s.Reader())
+//	}
+//	if err := s.Err(); err != nil {
+//		// handle error
+//	}
+type Scanner struct {
+	r      io.Reader
+	keep   func(Item) bool
+	header header
+	// index is the index of the current item, -1 before the first Next.
+	index   int
+	started bool
+	item    *streamItem
+	err     error
+}
+
+// NewScanner returns a Scanner that reads a blob from r. The header is read on
+// the first call to Next.
+func NewScanner(r io.Reader) *Scanner {
+	return &Scanner{r: r, index: -1}
+}
+
+// Filter sets a function that decides which items Next stops at. All other
+// items are skipped without buffering their data. Filter must be called before
+// the first call to Next.
+func (s *Scanner) Filter(keep func(item Item) bool) {
+	s.keep = keep
+}
+
+// Next advances to the next item, discarding all data of the current item that
+// was not read. It returns false when there are no more items or an error
+// occurred, see Err.
+func (s *Scanner) Next() bool {
+	if s.err != nil {
+		return false
+	}
+	if !s.started {
+		s.started = true
+		s.header, _, s.err = readHeader(s.r, ReadOptions{})
+		if s.err != nil {
+			return false
+		}
+		if s.header.indexOffset != 0 {
+			s.err = errors.New("blob.Scanner: blob has its header at the end, compact it first, see Editor.Compact")
+			return false
+		}
+	}
+	if s.item != nil {
+		if _, s.err = io.Copy(io.Discard, s.item); s.err != nil {
+			return false
+		}
+		s.item = nil
+	}
+
+	for s.index+1 < len(s.header.items) {
+		s.index++
+		item := &streamItem{r: s.r, n: int64(s.header.items[s.index].end - s.header.items[s.index].start)}
+		if s.keep == nil || s.keep(s.header.itemAt(s.index)) {
+			s.item = item
+			return true
+		}
+		if _, s.err = io.Copy(io.Discard, item); s.err != nil {
+			return false
+		}
+	}
+	return false
+}
+
+// Err returns the first error that occurred while scanning, or nil if the
+// whole blob was read successfully.
+func (s *Scanner) Err() error {
+	return s.err
+}
+
+// ID returns the ID of the current item.
+func (s *Scanner) ID() string {
+	if s.item == nil {
+		return ""
+	}
+	return s.header.items[s.index].id
+}
+
+// Size returns the data length of the current item in bytes.
+func (s *Scanner) Size() int64 {
+	if s.item == nil {
+		return 0
+	}
+	return s.header.itemAt(s.index).Size
+}
+
+// Reader returns a reader for the data of the current item. It is only valid
+// until the next call to Next.
+func (s *Scanner) Reader() io.Reader {
+	if s.item == nil {
+		return strings.NewReader("")
+	}
+	return s.item
+}
+
+// streamItem reads the next n bytes of r, it is an error if r ends before.
+type streamItem struct {
+	r io.Reader
+	n int64
+}
+
+func (r *streamItem) Read(p []byte) (int, error) {
+	if r.n <= 0 {
+		return 0, io.EOF
+	}
+	if int64(len(p)) > r.n {
+		p = p[:r.n]
+	}
+	n, err := r.r.Read(p)
+	r.n -= int64(n)
+	if err == io.EOF && r.n > 0 {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
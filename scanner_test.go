@@ -0,0 +1,104 @@
+package blob_test
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/gonutz/blob"
+)
+
+// onlyReader hides all methods but Read, making sure that Scanner does not
+// rely on seeking.
+type onlyReader struct {
+	r io.Reader
+}
+
+func (r onlyReader) Read(p []byte) (int, error) {
+	return r.r.Read(p)
+}
+
+func TestScannerReadsItemsInOrder(t *testing.T) {
+	b := blob.New()
+	b.Append("one", []byte("1"))
+	b.Append("skip.tmp", []byte("skipped"))
+	b.Append("two", []byte("22"))
+	b.Append("empty", nil)
+	data, _ := b.MarshalBinary()
+	s := blob.NewScanner(onlyReader{bytes.NewReader(data)})
+
+	var ids []string
+	var sizes []int64
+	var contents []string
+	for s.Next() {
+		ids = append(ids, s.ID())
+		sizes = append(sizes, s.Size())
+		if s.ID() != "skip.tmp" {
+			// skip.tmp is not read, Next discards it
+			all, err := io.ReadAll(s.Reader())
+			if err != nil {
+				t.Fatal(err)
+			}
+			contents = append(contents, string(all))
+		}
+	}
+	if err := s.Err(); err != nil {
+		t.Fatal(err)
+	}
+	checkStrings(t, ids, []string{"one", "skip.tmp", "two", "empty"})
+	checkStrings(t, contents, []string{"1", "22", ""})
+	if sizes[1] != 7 || sizes[2] != 2 {
+		t.Error("sizes were", sizes)
+	}
+}
+
+func TestScannerFilterSkipsItems(t *testing.T) {
+	b := blob.New()
+	b.Append("one", []byte("1"))
+	b.Append("skip.tmp", []byte("skipped"))
+	b.Append("two", []byte("22"))
+	b.Append("empty", nil)
+	data, _ := b.MarshalBinary()
+	s := blob.NewScanner(onlyReader{bytes.NewReader(data)})
+	s.Filter(func(item blob.Item) bool {
+		return !strings.HasSuffix(item.ID, ".tmp")
+	})
+
+	var ids []string
+	for s.Next() {
+		if s.ID() == "two" {
+			// read only part of it
+			var first [1]byte
+			s.Reader().Read(first[:])
+		}
+		ids = append(ids, s.ID())
+	}
+	if err := s.Err(); err != nil {
+		t.Fatal(err)
+	}
+	checkStrings(t, ids, []string{"one", "two", "empty"})
+}
+
+func TestScannerReportsTruncatedInput(t *testing.T) {
+	b := blob.New()
+	b.Append("one", []byte("1"))
+	b.Append("two", []byte("22"))
+	data, _ := b.MarshalBinary()
+	s := blob.NewScanner(bytes.NewReader(data[:len(data)-2]))
+
+	for s.Next() {
+	}
+
+	if s.Err() != io.ErrUnexpectedEOF {
+		t.Error("want unexpected EOF but got", s.Err())
+	}
+
+	s = blob.NewScanner(strings.NewReader("x"))
+	if s.Next() {
+		t.Error("Next must fail for broken header")
+	}
+	if s.Err() == nil {
+		t.Error("error expected for broken header")
+	}
+}
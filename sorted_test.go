@@ -0,0 +1,147 @@
+package blob_test
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/gonutz/blob"
+)
+
+func TestSortIDsWritesSortedHeaderAndData(t *testing.T) {
+	b := blob.New()
+	b.Append("b", []byte{1})
+	b.Append("a", []byte{2, 3})
+	b.Append("b", []byte{4})
+	var buf bytes.Buffer
+
+	err := b.WriteWithOptions(&buf, blob.WriteOptions{SortIDs: true})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkBytes(t, buf.Bytes(), []byte{
+		33, 0, 0, 0x80, // highest bit is the sorted flag
+		1, 0, 'a', 2, 0, 0, 0, 0, 0, 0, 0,
+		1, 0, 'b', 1, 0, 0, 0, 0, 0, 0, 0,
+		1, 0, 'b', 1, 0, 0, 0, 0, 0, 0, 0,
+		2, 3, // data for "a"
+		1, // data for the first "b"
+		4, // data for the second "b"
+	})
+}
+
+func TestSortedLayoutDoesNotDependOnAppendOrder(t *testing.T) {
+	write := func(ids ...string) []byte {
+		b := blob.New()
+		for _, id := range ids {
+			b.Append(id, []byte(id))
+		}
+		var buf bytes.Buffer
+		if err := b.WriteWithOptions(&buf, blob.WriteOptions{SortIDs: true}); err != nil {
+			t.Fatal(err)
+		}
+		return buf.Bytes()
+	}
+	checkBytes(t, write("x", "y", "z"), write("z", "x", "y"))
+}
+
+func TestSortedBlobFindsFirstOfEqualIDs(t *testing.T) {
+	b := blob.New()
+	b.Append("c", []byte{1})
+	b.Append("a", []byte{2})
+	b.Append("c", []byte{3})
+	b.Append("b", []byte{4})
+	var buf bytes.Buffer
+	b.WriteWithOptions(&buf, blob.WriteOptions{SortIDs: true})
+
+	read, err := blob.Read(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"a", "b", "c"} {
+		want, _ := b.GetByID(id)
+		have, found := read.GetByID(id)
+		if !found {
+			t.Fatal(id, "not found")
+		}
+		checkBytes(t, have, want)
+	}
+	if _, found := read.GetByID("d"); found {
+		t.Error("d must not be found")
+	}
+
+	opened, err := blob.Open(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, found := opened.GetByID("c")
+	if !found {
+		t.Fatal("c not found")
+	}
+	data, _ := io.ReadAll(r)
+	checkBytes(t, data, []byte{1})
+	if _, found := opened.GetByID("0"); found {
+		t.Error("0 must not be found")
+	}
+}
+
+func TestRangeIteratesInLexicalOrder(t *testing.T) {
+	b := blob.New()
+	for _, id := range []string{"d", "a", "c", "b", "c"} {
+		b.Append(id, []byte(id))
+	}
+	var sorted bytes.Buffer
+	b.WriteWithOptions(&sorted, blob.WriteOptions{SortIDs: true})
+	var unsorted bytes.Buffer
+	b.Write(&unsorted)
+
+	for _, data := range [][]byte{sorted.Bytes(), unsorted.Bytes()} {
+		read, err := blob.Read(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ids []string
+		for id := range read.Range("b", "d") {
+			ids = append(ids, id)
+		}
+		checkStrings(t, ids, []string{"b", "c", "c"})
+
+		ids = nil
+		for id := range read.Range("c", "") {
+			ids = append(ids, id)
+		}
+		checkStrings(t, ids, []string{"c", "c", "d"})
+
+		opened, err := blob.Open(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = nil
+		for item, r := range opened.Range("", "c") {
+			ids = append(ids, item.ID)
+			all, _ := io.ReadAll(r)
+			checkBytes(t, all, []byte(item.ID))
+		}
+		checkStrings(t, ids, []string{"a", "b"})
+	}
+}
+
+func TestReadingHeaderFlaggedSortedThatIsNotFails(t *testing.T) {
+	_, err := blob.Read(bytes.NewReader([]byte{
+		22, 0, 0, 0x80,
+		1, 0, 'b', 0, 0, 0, 0, 0, 0, 0, 0,
+		1, 0, 'a', 0, 0, 0, 0, 0, 0, 0, 0,
+	}))
+	if err == nil || !strings.Contains(err.Error(), "sorted") {
+		t.Error("want error about sorting but got", err)
+	}
+}
+
+func TestReadingUnknownHeaderFlagsFails(t *testing.T) {
+	_, err := blob.Read(bytes.NewReader([]byte{0, 0, 0, 0x40}))
+	if err == nil {
+		t.Error("error expected for unknown flag")
+	}
+}
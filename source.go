@@ -0,0 +1,251 @@
+package blob
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strconv"
+)
+
+// Source is the common interface of everything that holds blob items, like
+// Blob, BlobReader, Cache, Overlay and Dir. Code that only needs to look up and
+// read items can be written once against Source and work with data in memory
+// as well as on disk.
+type Source interface {
+	// ItemCount returns the number of items, valid indices for GetIDAtIndex
+	// range from 0 to ItemCount()-1.
+	ItemCount() int
+	// GetIDAtIndex returns the ID of the entry at index i or the empty string
+	// if the given index is out of bounds.
+	GetIDAtIndex(i int) string
+	// Open returns a reader for the data of the first entry with the given
+	// ID. If there is no such entry, the error wraps ErrNotFound.
+	Open(id string) (ItemReader, error)
+	// ReadAll returns the data of the first entry with the given ID. The
+	// returned slice may be shared with the Source and must not be modified.
+	// If there is no such entry, the error wraps ErrNotFound.
+	ReadAll(id string) ([]byte, error)
+}
+
+// ItemReader reads the data of a single item, see Source. Closing it releases
+// the reader, it does not close the Source.
+type ItemReader interface {
+	io.ReadSeekCloser
+	io.ReaderAt
+	// Size returns the length of the item's data in bytes.
+	Size() int64
+}
+
+var (
+	_ Source = (*Blob)(nil)
+	_ Source = (*BlobReader)(nil)
+	_ Source = (*Cache)(nil)
+	_ Source = (*Overlay)(nil)
+	_ Source = (*Dir)(nil)
+
+	_ versionedSource = (*Overlay)(nil)
+	_ versionedSource = (*Dir)(nil)
+)
+
+// serialSource is implemented by Sources whose item readers may share state,
+// like the io.ReadSeeker of a BlobReader returned by Open.
+type serialSource interface {
+	// serialReads reports whether reads from different item readers must not
+	// happen in parallel.
+	serialReads() bool
+}
+
+func (b *BlobReader) serialReads() bool {
+	return b.at == nil
+}
+
+func (o *Overlay) serialReads() bool {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	for _, layer := range o.layers {
+		if layer.serial {
+			return true
+		}
+	}
+	return false
+}
+
+// versionedSource is implemented by Sources whose items can change, so
+// anything derived from an item's data, like an ETag, must be computed again.
+type versionedSource interface {
+	// version returns a string that changes whenever the data of the item
+	// with the given ID may have changed. r is the open reader for the item.
+	version(id string, r ItemReader) string
+}
+
+// version identifies the layer entry that r reads. Pushing a layer may change
+// the entry that the ID resolves to.
+func (o *Overlay) version(id string, r ItemReader) string {
+	item, ok := o.find(id)
+	if r, opened := r.(overlayReader); opened {
+		// a layer may have been pushed since r was opened
+		item, ok = r.item, true
+	}
+	if !ok {
+		return ""
+	}
+	return strconv.Itoa(item.layer) + ":" + strconv.Itoa(item.index)
+}
+
+// Open returns a reader for the data of the first entry with the given ID. If
+// there is no such entry, the error wraps ErrNotFound.
+func (b *Blob) Open(id string) (ItemReader, error) {
+	data, err := b.readAll("blob.Blob.Open", id)
+	if err != nil {
+		return nil, err
+	}
+	return bytesItem{bytes.NewReader(data)}, nil
+}
+
+// ReadAll returns the data of the first entry with the given ID. The returned
+// slice is shared with the blob, like for GetByID. If there is no such entry,
+// the error wraps ErrNotFound. Unlike GetByID, it reports errors reading the
+// data of items added with AppendReader or AppendFile.
+func (b *Blob) ReadAll(id string) ([]byte, error) {
+	return b.readAll("blob.Blob.ReadAll", id)
+}
+
+func (b *Blob) readAll(context, id string) ([]byte, error) {
+	i, found := b.find(id)
+	if !found {
+		return nil, notFoundError(context, id)
+	}
+	data, err := b.load(&b.items[i])
+	if err != nil {
+		return nil, itemError(context, id, err)
+	}
+	return data, nil
+}
+
+// Open returns a reader for the data of the first entry with the given ID. If
+// there is no such entry, the error wraps ErrNotFound. The same restrictions
+// as for the readers returned by GetByID apply.
+func (b *BlobReader) Open(id string) (ItemReader, error) {
+	i, found := b.find(id)
+	if !found {
+		return nil, notFoundError("blob.BlobReader.Open", id)
+	}
+	return b.item(i), nil
+}
+
+// ReadAll reads the data of the first entry with the given ID into a new
+// slice. If there is no such entry, the error wraps ErrNotFound.
+func (b *BlobReader) ReadAll(id string) ([]byte, error) {
+	i, found := b.find(id)
+	if !found {
+		return nil, notFoundError("blob.BlobReader.ReadAll", id)
+	}
+	r := b.item(i)
+	data := make([]byte, r.Size())
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, itemError("blob.BlobReader.ReadAll", id, err)
+	}
+	return data, nil
+}
+
+// Open returns a reader for the data of the first entry with the given ID,
+// loading it into the cache if necessary. If there is no such entry, the error
+// wraps ErrNotFound.
+func (c *Cache) Open(id string) (ItemReader, error) {
+	data, err := c.getByID("blob.Cache.Open", id)
+	if err != nil {
+		return nil, err
+	}
+	return bytesItem{bytes.NewReader(data)}, nil
+}
+
+// ReadAll is the same as GetByID, it makes Cache a Source.
+func (c *Cache) ReadAll(id string) ([]byte, error) {
+	return c.getByID("blob.Cache.ReadAll", id)
+}
+
+// Open returns a reader for the data of the given ID from the topmost layer
+// that has it. If no layer has the ID or it is hidden by a whiteout entry, the
+// error wraps ErrNotFound.
+func (o *Overlay) Open(id string) (ItemReader, error) {
+	item, found := o.find(id)
+	if !found {
+		return nil, notFoundError("blob.Overlay.Open", id)
+	}
+	r, err := o.open(item)
+	if err != nil {
+		return nil, itemError("blob.Overlay.Open", id, err)
+	}
+	return overlayReader{r, item}, nil
+}
+
+// overlayReader is the ItemReader of an Overlay. It remembers the layer entry
+// that it reads, see version.
+type overlayReader struct {
+	ItemReader
+	item overlayItem
+}
+
+// ReadAll reads the data of the given ID from the topmost layer that has it.
+// If no layer has the ID or it is hidden by a whiteout entry, the error wraps
+// ErrNotFound.
+func (o *Overlay) ReadAll(id string) ([]byte, error) {
+	r, err := o.Open(id)
+	if err != nil {
+		return nil, err
+	}
+	data := make([]byte, r.Size())
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, itemError("blob.Overlay.ReadAll", id, err)
+	}
+	return data, nil
+}
+
+// bytesItem is the ItemReader for data in memory.
+type bytesItem struct {
+	*bytes.Reader
+}
+
+func (bytesItem) Close() error { return nil }
+
+// ReadAt reads len(p) bytes of the item's data starting at offset off. It does
+// not change the reader's position. Without OpenPath it has to seek the
+// underlying io.ReadSeeker, so the restrictions described on Open apply.
+func (r *reader) ReadAt(p []byte, off int64) (n int, err error) {
+	if r.owner.closed.Load() {
+		return 0, ErrClosed
+	}
+	if off < 0 {
+		return 0, errors.New("blob.reader.ReadAt: negative offset")
+	}
+	if off >= r.Size() {
+		return 0, io.EOF
+	}
+	want := len(p)
+	if int64(len(p)) > r.Size()-off {
+		p = p[:r.Size()-off]
+	}
+	if r.owner.at != nil {
+		n, err = r.owner.at.ReadAt(p, r.start+off)
+	} else if _, err = r.owner.r.Seek(r.start+off, io.SeekStart); err == nil {
+		n, err = io.ReadFull(r.owner.r, p)
+	}
+	if n == len(p) {
+		err = nil
+		if n < want {
+			err = io.EOF
+		}
+	}
+	return n, err
+}
+
+// Size returns the length of the item's data in bytes.
+func (r *reader) Size() int64 {
+	return r.end - r.start
+}
+
+// Close does nothing, the item reader does not own any resources. Close the
+// BlobReader instead.
+func (r *reader) Close() error {
+	return nil
+}
@@ -0,0 +1,90 @@
+package blob_test
+
+import (
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/gonutz/blob"
+)
+
+// sourcesOf returns every Source implementation over the items of b, by name.
+func sourcesOf(t *testing.T, b *blob.Blob) map[string]blob.Source {
+	overlay := blob.NewOverlay()
+	overlay.PushBlobReader(openBlob(t, b))
+	return map[string]blob.Source{
+		"Blob":       b,
+		"BlobReader": openBlob(t, b),
+		"Cache":      blob.NewCache(openBlob(t, b), 100),
+		"Overlay":    overlay,
+	}
+}
+
+func TestSourcesReadTheFirstItemWithAnID(t *testing.T) {
+	b := blob.New()
+	b.Append("a", []byte("first"))
+	b.Append("a", []byte("second"))
+
+	for name, s := range sourcesOf(t, b) {
+		t.Run(name, func(t *testing.T) {
+			data, err := s.ReadAll("a")
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkBytes(t, data, []byte("first"))
+
+			r, err := s.Open("a")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer r.Close()
+			if r.Size() != 5 {
+				t.Error("want size 5 but have", r.Size())
+			}
+			all, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkBytes(t, all, []byte("first"))
+
+			if _, err := s.Open("missing"); !errors.Is(err, blob.ErrNotFound) {
+				t.Error("want ErrNotFound but have", err)
+			}
+			if _, err := s.ReadAll("missing"); !errors.Is(err, blob.ErrNotFound) {
+				t.Error("want ErrNotFound but have", err)
+			}
+		})
+	}
+}
+
+func TestItemReaderReadAt(t *testing.T) {
+	b := blob.New()
+	b.Append("b", []byte("0123456789"))
+
+	for name, s := range sourcesOf(t, b) {
+		t.Run(name, func(t *testing.T) {
+			r, err := s.Open("b")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer r.Close()
+
+			p := make([]byte, 3)
+			n, err := r.ReadAt(p, 2)
+			if n != 3 || err != nil {
+				t.Fatal("ReadAt returned", n, err)
+			}
+			checkBytes(t, p, []byte("234"))
+
+			n, err = r.ReadAt(p, 8)
+			if n != 2 || err != io.EOF {
+				t.Error("want 2, EOF at the end but have", n, err)
+			}
+			checkBytes(t, p[:n], []byte("89"))
+
+			// ReadAt does not move the read position
+			first, _ := io.ReadAll(io.LimitReader(r, 2))
+			checkBytes(t, first, []byte("01"))
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package blob
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// AppendJSON encodes v as JSON and appends it under the given ID, see
+// json.Marshal.
+func (b *Blob) AppendJSON(id string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return itemError("blob.Blob.AppendJSON", id, err)
+	}
+	b.Append(id, data)
+	return nil
+}
+
+// AppendGob encodes v with encoding/gob and appends it under the given ID.
+// Every item is a complete gob stream of its own, so it can be decoded
+// without the other items.
+func (b *Blob) AppendGob(id string, v any) error {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return itemError("blob.Blob.AppendGob", id, err)
+	}
+	b.Append(id, buf.Bytes())
+	return nil
+}
+
+// GetJSON decodes the JSON data of the first entry with the given ID into v,
+// see json.Unmarshal. If there is no such entry, the error wraps ErrNotFound.
+func (b *Blob) GetJSON(id string, v any) error {
+	data, err := b.readAll("blob.Blob.GetJSON", id)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return itemError("blob.Blob.GetJSON", id, err)
+	}
+	return nil
+}
+
+// GetGob decodes the gob data of the first entry with the given ID into v, see
+// AppendGob. If there is no such entry, the error wraps ErrNotFound.
+func (b *Blob) GetGob(id string, v any) error {
+	data, err := b.readAll("blob.Blob.GetGob", id)
+	if err != nil {
+		return err
+	}
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(v); err != nil {
+		return itemError("blob.Blob.GetGob", id, err)
+	}
+	return nil
+}
+
+// GetString returns the data of the first entry with the given ID as a
+// string. If there is no such entry, the error wraps ErrNotFound.
+func (b *Blob) GetString(id string) (string, error) {
+	data, err := b.readAll("blob.Blob.GetString", id)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// GetJSON decodes the JSON data of the first entry with the given ID into v.
+// The data is decoded while it is read, it is not buffered as a whole. If
+// there is no such entry, the error wraps ErrNotFound.
+func (b *BlobReader) GetJSON(id string, v any) error {
+	r, found := b.GetByID(id)
+	if !found {
+		return notFoundError("blob.BlobReader.GetJSON", id)
+	}
+	dec := json.NewDecoder(r)
+	if err := dec.Decode(v); err != nil {
+		return itemError("blob.BlobReader.GetJSON", id, err)
+	}
+	// like json.Unmarshal, which Blob.GetJSON uses, reject anything but white
+	// space after the value
+	if _, err := dec.Token(); err != io.EOF {
+		return itemError("blob.BlobReader.GetJSON", id, errors.New("invalid data after the JSON value"))
+	}
+	return nil
+}
+
+// GetGob decodes the gob data of the first entry with the given ID into v. The
+// data is decoded while it is read, it is not buffered as a whole. If there is
+// no such entry, the error wraps ErrNotFound.
+func (b *BlobReader) GetGob(id string, v any) error {
+	r, found := b.GetByID(id)
+	if !found {
+		return notFoundError("blob.BlobReader.GetGob", id)
+	}
+	if err := gob.NewDecoder(r).Decode(v); err != nil {
+		return itemError("blob.BlobReader.GetGob", id, err)
+	}
+	return nil
+}
+
+// GetString reads the data of the first entry with the given ID and returns it
+// as a string. If there is no such entry, the error wraps ErrNotFound.
+func (b *BlobReader) GetString(id string) (string, error) {
+	i, found := b.find(id)
+	if !found {
+		return "", notFoundError("blob.BlobReader.GetString", id)
+	}
+	var s strings.Builder
+	s.Grow(int(b.items[i].end - b.items[i].start))
+	if err := b.copyItem(&s, i); err != nil {
+		return "", itemError("blob.BlobReader.GetString", id, err)
+	}
+	return s.String(), nil
+}
+
+func notFoundError(context, id string) error {
+	return fmt.Errorf("%s: %w: %q", context, ErrNotFound, id)
+}
+
+func itemError(context, id string, err error) error {
+	return fmt.Errorf("%s: item %q: %w", context, id, err)
+}
@@ -0,0 +1,106 @@
+package blob_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/gonutz/blob"
+)
+
+type valuesTestConfig struct {
+	Name  string
+	Sizes []int
+}
+
+func TestJSONAndGobValuesRoundTrip(t *testing.T) {
+	want := valuesTestConfig{Name: "level1", Sizes: []int{1, 2, 3}}
+	b := blob.New()
+	if err := b.AppendJSON("config.json", want); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.AppendGob("config.gob", want); err != nil {
+		t.Fatal(err)
+	}
+	b.Append("name", []byte("level1"))
+	br := openBlob(t, b)
+
+	check := func(context string, have valuesTestConfig, err error) {
+		t.Helper()
+		if err != nil {
+			t.Error(context, err)
+		} else if have.Name != want.Name || len(have.Sizes) != 3 || have.Sizes[2] != 3 {
+			t.Error(context, "want", want, "but have", have)
+		}
+	}
+	var v1, v2, v3, v4 valuesTestConfig
+	check("Blob.GetJSON", v1, b.GetJSON("config.json", &v1))
+	check("Blob.GetGob", v2, b.GetGob("config.gob", &v2))
+	check("BlobReader.GetJSON", v3, br.GetJSON("config.json", &v3))
+	check("BlobReader.GetGob", v4, br.GetGob("config.gob", &v4))
+
+	for _, get := range []func(string) (string, error){b.GetString, br.GetString} {
+		s, err := get("name")
+		if err != nil || s != "level1" {
+			t.Errorf("GetString: want level1 but have %q, %v", s, err)
+		}
+	}
+}
+
+func TestValueErrorsNameTheItem(t *testing.T) {
+	b := blob.New()
+	b.Append("broken.json", []byte("{"))
+	br := openBlob(t, b)
+	var v valuesTestConfig
+
+	for _, err := range []error{
+		b.GetJSON("broken.json", &v),
+		br.GetJSON("broken.json", &v),
+		b.GetGob("broken.json", &v),
+		br.GetGob("broken.json", &v),
+	} {
+		if err == nil || !strings.Contains(err.Error(), `"broken.json"`) {
+			t.Error("error must name the item but is", err)
+		}
+	}
+
+	_, err := br.GetString("missing")
+	if !errors.Is(err, blob.ErrNotFound) || !strings.Contains(err.Error(), `"missing"`) {
+		t.Error("want not found error naming the item but have", err)
+	}
+	if err := b.GetJSON("missing", &v); !errors.Is(err, blob.ErrNotFound) {
+		t.Error("want not found error but have", err)
+	}
+}
+
+func TestAppendJSONFailsForUnsupportedValues(t *testing.T) {
+	b := blob.New()
+	err := b.AppendJSON("func", func() {})
+	if err == nil || !strings.Contains(err.Error(), `"func"`) {
+		t.Error("error must name the item but is", err)
+	}
+	if b.ItemCount() != 0 {
+		t.Error("nothing must be appended on error")
+	}
+}
+
+func TestGetJSONRejectsDataAfterTheValue(t *testing.T) {
+	b := blob.New()
+	b.Append("two.json", []byte(`{"Name":"a"} {"Name":"b"}`))
+	b.Append("garbage.json", []byte(`{"Name":"a"} x`))
+	b.Append("space.json", []byte("{\"Name\":\"a\"}\n  "))
+	br := openBlob(t, b)
+
+	for _, get := range []func(string, any) error{b.GetJSON, br.GetJSON} {
+		var v valuesTestConfig
+		if err := get("two.json", &v); err == nil {
+			t.Error("error expected for two values")
+		}
+		if err := get("garbage.json", &v); err == nil {
+			t.Error("error expected for trailing garbage")
+		}
+		if err := get("space.json", &v); err != nil {
+			t.Error("trailing white space is allowed but got", err)
+		}
+	}
+}
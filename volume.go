@@ -0,0 +1,255 @@
+package blob
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// VolumePath returns the path of the volume with the given index of a blob
+// split into several files, e.g. "name.blob.000" for index 0.
+func VolumePath(name string, index int) string {
+	return fmt.Sprintf("%s.%03d", name, index)
+}
+
+// VolumePaths returns the paths of all volumes of the split blob with the given
+// name, in order. It starts at VolumePath(name, 0) and stops at the first
+// volume that does not exist. It is an error if there are no volumes at all.
+func VolumePaths(name string) ([]string, error) {
+	var paths []string
+	for i := 0; ; i++ {
+		path := VolumePath(name, i)
+		if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		paths = append(paths, path)
+	}
+	if len(paths) == 0 {
+		return nil, errors.New("no volumes found for " + name)
+	}
+	return paths, nil
+}
+
+// WriteVolumes writes the blob like WriteWithOptions but splits the output into
+// files that are at most size bytes long. The files are named as given by
+// VolumePath. The header is never split, WriteVolumes fails if it does not fit
+// into the first volume. Volumes of an earlier, longer version of the blob that
+// are not needed anymore are removed.
+//
+// Like for WriteFile, the volumes are written to temporary files and synced to
+// disk first. Only when all of them were written, they are renamed to their
+// final names. If writing fails, the temporary files are removed and existing
+// volumes are left unchanged. A crash while renaming can still leave a mix of
+// old and new volumes behind. WriteVolumes does not support opts.Backup.
+//
+// Use OpenVolumes to read the split blob.
+func (b *Blob) WriteVolumes(name string, size int64, opts WriteOptions) error {
+	return b.WriteVolumesContext(context.Background(), name, size, opts)
+}
+
+// WriteVolumesContext writes the volumes like WriteVolumes but stops when ctx
+// is canceled, see WriteContext. Existing volumes are left unchanged in that
+// case.
+func (b *Blob) WriteVolumesContext(ctx context.Context, name string, size int64, opts WriteOptions) error {
+	if size <= 0 {
+		return errors.New("blob.Blob.WriteVolumes: volume size must be positive")
+	}
+	if opts.Backup {
+		return errors.New("blob.Blob.WriteVolumes: backups are not supported for volumes")
+	}
+	items := b.items
+	if opts.SortIDs && !b.sorted {
+		items = sortedItems(items)
+	}
+	headerData, err := encodeHeader(items, opts.SortIDs)
+	if err != nil {
+		return errors.New("blob.Blob.WriteVolumes: " + err.Error())
+	}
+	if int64(len(headerData)) > size {
+		return errors.New("blob.Blob.WriteVolumes: header does not fit into one volume")
+	}
+
+	w := &volumeWriter{name: name, size: size}
+	err = b.WriteContext(ctx, w, opts)
+	if closeErr := w.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		w.remove(0)
+		return err
+	}
+	for i, f := range w.files {
+		if err := os.Rename(f.Name(), VolumePath(name, i)); err != nil {
+			w.remove(i)
+			return errors.New("blob.Blob.WriteVolumes: " + err.Error())
+		}
+	}
+
+	// remove left-over volumes of an earlier version
+	for i := len(w.files); ; i++ {
+		err := os.Remove(VolumePath(name, i))
+		if errors.Is(err, os.ErrNotExist) {
+			break
+		}
+		if err != nil {
+			return errors.New("blob.Blob.WriteVolumes: " + err.Error())
+		}
+	}
+	if err := syncDir(filepath.Dir(name)); err != nil {
+		return fmt.Errorf("blob.Blob.WriteVolumes: %w: %v", ErrDirNotSynced, err)
+	}
+	return nil
+}
+
+// volumeWriter writes to a series of temporary files, each at most size bytes
+// long. A new file is only created when there is data for it. The files are
+// synced to disk when they are full.
+type volumeWriter struct {
+	name    string
+	size    int64
+	files   []*os.File // the last one is open for writing
+	written int64
+}
+
+func (w *volumeWriter) Write(p []byte) (int, error) {
+	var n int
+	if len(w.files) == 0 {
+		if err := w.create(); err != nil {
+			return 0, err
+		}
+	}
+	for len(p) > 0 {
+		if w.written == w.size {
+			if err := w.Close(); err != nil {
+				return n, err
+			}
+			if err := w.create(); err != nil {
+				return n, err
+			}
+		}
+		chunk := p[:min(int64(len(p)), w.size-w.written)]
+		m, err := w.files[len(w.files)-1].Write(chunk)
+		n += m
+		w.written += int64(m)
+		if err != nil {
+			return n, err
+		}
+		p = p[m:]
+	}
+	return n, nil
+}
+
+func (w *volumeWriter) create() error {
+	final := VolumePath(w.name, len(w.files))
+	f, err := os.CreateTemp(filepath.Dir(final), "."+filepath.Base(final)+".tmp*")
+	if err != nil {
+		return err
+	}
+	w.files = append(w.files, f)
+	w.written = 0
+	return f.Chmod(0644)
+}
+
+// Close syncs and closes the current file.
+func (w *volumeWriter) Close() error {
+	if len(w.files) == 0 {
+		// an empty blob still has a header so this only happens on errors
+		return nil
+	}
+	f := w.files[len(w.files)-1]
+	if err := f.Sync(); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// remove removes the temporary files starting at the given index.
+func (w *volumeWriter) remove(from int) {
+	for _, f := range w.files[from:] {
+		f.Close()
+		os.Remove(f.Name())
+	}
+}
+
+// OpenVolumes opens a blob that was split into the given volumes, see
+// WriteVolumes. The volumes must be given in order. The returned BlobReader
+// behaves as if the volumes were one file, item readers cross the volume
+// boundaries transparently.
+//
+// Like for Open, the volumes are not closed by the BlobReader and the same
+// restrictions for reading apply.
+func OpenVolumes(volumes ...io.ReadSeeker) (*BlobReader, error) {
+	r, err := newMultiReadSeeker(volumes)
+	if err != nil {
+		return nil, errors.New("open blob volumes: " + err.Error())
+	}
+	return Open(r)
+}
+
+// multiReadSeeker concatenates several io.ReadSeekers.
+type multiReadSeeker struct {
+	parts []io.ReadSeeker
+	// starts holds the offset of each part, followed by the overall size.
+	starts []int64
+	pos    int64
+}
+
+func newMultiReadSeeker(parts []io.ReadSeeker) (*multiReadSeeker, error) {
+	r := &multiReadSeeker{parts: parts, starts: make([]int64, len(parts)+1)}
+	for i, part := range parts {
+		size, err := part.Seek(0, io.SeekEnd)
+		if err != nil {
+			return nil, err
+		}
+		r.starts[i+1] = r.starts[i] + size
+	}
+	return r, nil
+}
+
+func (r *multiReadSeeker) Read(p []byte) (int, error) {
+	size := r.starts[len(r.parts)]
+	if r.pos >= size {
+		return 0, io.EOF
+	}
+	// find the part that contains pos, empty parts never do
+	i := sort.Search(len(r.parts), func(i int) bool {
+		return r.starts[i+1] > r.pos
+	})
+	if _, err := r.parts[i].Seek(r.pos-r.starts[i], io.SeekStart); err != nil {
+		return 0, err
+	}
+	if rest := r.starts[i+1] - r.pos; int64(len(p)) > rest {
+		p = p[:rest]
+	}
+	n, err := r.parts[i].Read(p)
+	r.pos += int64(n)
+	if err == io.EOF && r.pos < size {
+		err = nil
+	}
+	return n, err
+}
+
+func (r *multiReadSeeker) Seek(offset int64, whence int) (int64, error) {
+	var pos int64
+	switch whence {
+	case io.SeekStart:
+		pos = offset
+	case io.SeekCurrent:
+		pos = r.pos + offset
+	case io.SeekEnd:
+		pos = r.starts[len(r.parts)] + offset
+	default:
+		return r.pos, errors.New("blob.multiReadSeeker.Seek: invalid whence")
+	}
+	if pos < 0 {
+		return r.pos, errors.New("blob.multiReadSeeker.Seek: negative position")
+	}
+	r.pos = pos
+	return pos, nil
+}
@@ -0,0 +1,142 @@
+package blob_test
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gonutz/blob"
+)
+
+func TestWriteAndOpenVolumes(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "test.blob")
+	b := blob.New()
+	b.Append("one", []byte("0123456789"))
+	b.Append("two", []byte("abcdefghij"))
+	b.Append("three", []byte("ABCDEFGHIJ"))
+	var whole bytes.Buffer
+	b.Write(&whole)
+
+	// the header is 4+3*10+3+3+5 = 45 bytes long, data is 30 bytes
+	if err := b.WriteVolumes(name, 50, blob.WriteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	paths, err := blob.VolumePaths(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkStrings(t, paths, []string{name + ".000", name + ".001"})
+	var joined []byte
+	for _, path := range paths {
+		data, _ := os.ReadFile(path)
+		joined = append(joined, data...)
+	}
+	checkBytes(t, joined, whole.Bytes())
+
+	var volumes []io.ReadSeeker
+	for _, path := range paths {
+		f, err := os.Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		volumes = append(volumes, f)
+	}
+	br, err := blob.OpenVolumes(volumes...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for id, want := range map[string]string{
+		"one":   "0123456789",
+		"two":   "abcdefghij", // crosses the volume boundary
+		"three": "ABCDEFGHIJ",
+	} {
+		r, found := br.GetByID(id)
+		if !found {
+			t.Fatal(id, "not found")
+		}
+		data, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != want {
+			t.Errorf("%s: want %q but have %q", id, want, data)
+		}
+	}
+
+	r, _ := br.GetByID("two")
+	r.Seek(3, io.SeekStart)
+	var buf [4]byte
+	n, err := io.ReadFull(r, buf[:])
+	if err != nil || string(buf[:n]) != "defg" {
+		t.Error("read after seek:", n, err, string(buf[:n]))
+	}
+}
+
+func TestWriteVolumesRemovesOldVolumes(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "test.blob")
+	b := blob.New()
+	b.Append("id", make([]byte, 100))
+	if err := b.WriteVolumes(name, 20, blob.WriteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	paths, _ := blob.VolumePaths(name)
+	if len(paths) != 6 {
+		t.Fatal("want 6 volumes but have", len(paths))
+	}
+
+	b = blob.New()
+	b.Append("id", make([]byte, 10))
+	if err := b.WriteVolumes(name, 20, blob.WriteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	paths, _ = blob.VolumePaths(name)
+	checkStrings(t, paths, []string{name + ".000", name + ".001"})
+	if _, err := os.Stat(name + ".005"); !os.IsNotExist(err) {
+		t.Error("old volume was not removed")
+	}
+}
+
+func TestHeaderMustFitIntoFirstVolume(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "test.blob")
+	b := blob.New()
+	b.Append("a long ID that makes the header large", nil)
+	if err := b.WriteVolumes(name, 20, blob.WriteOptions{}); err == nil {
+		t.Error("error expected")
+	}
+	if _, err := blob.VolumePaths(name); err == nil {
+		t.Error("no volumes should have been written")
+	}
+}
+
+func TestFailedWriteVolumesLeavesOldVolumes(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "test.blob")
+	old := blob.New()
+	old.Append("id", []byte("old data"))
+	if err := old.WriteVolumes(name, 20, blob.WriteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	oldPaths, _ := blob.VolumePaths(name)
+	oldFirst, _ := os.ReadFile(oldPaths[0])
+
+	b := blob.New()
+	b.Append("id", make([]byte, 10))
+	// the second item fails after the first volumes were written
+	b.AppendReader("broken", &failingReader{r: bytes.NewReader(make([]byte, 100)), failAtRead: 0}, 100)
+	if err := b.WriteVolumes(name, 20, blob.WriteOptions{}); err == nil {
+		t.Fatal("error expected")
+	}
+
+	paths, _ := blob.VolumePaths(name)
+	checkStrings(t, paths, oldPaths)
+	first, _ := os.ReadFile(paths[0])
+	checkBytes(t, first, oldFirst)
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != len(oldPaths) {
+		t.Error("temporary files were left behind, have", len(entries), "files")
+	}
+}
@@ -0,0 +1,132 @@
+package blob
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"strconv"
+)
+
+// WADNameLen is the maximum length of a lump name in a Doom WAD file.
+const WADNameLen = 8
+
+// FromWAD reads a Doom IWAD or PWAD file and returns a blob with one item per
+// lump, in directory order. The lump names become the IDs. Zero-length marker
+// lumps, like S_START and S_END, become empty items so the order of markers
+// and the lumps between them is kept.
+func FromWAD(r io.ReaderAt) (*Blob, error) {
+	var header [12]byte
+	if _, err := r.ReadAt(header[:], 0); err != nil {
+		return nil, errors.New("read WAD header: " + err.Error())
+	}
+	if id := string(header[:4]); id != "IWAD" && id != "PWAD" {
+		return nil, errors.New("read WAD header: not a WAD file")
+	}
+	lumpCount := int32(byteOrder.Uint32(header[4:]))
+	dirOffset := int32(byteOrder.Uint32(header[8:]))
+	if lumpCount < 0 || dirOffset < 0 {
+		return nil, errors.New("read WAD header: negative lump count or directory offset")
+	}
+
+	dir, err := readBytes(
+		io.NewSectionReader(r, int64(dirOffset), int64(lumpCount)*16),
+		uint64(lumpCount)*16,
+	)
+	if err != nil {
+		return nil, errors.New("read WAD directory: " + err.Error())
+	}
+
+	b := New()
+	for len(dir) > 0 {
+		offset := int32(byteOrder.Uint32(dir[0:]))
+		size := int32(byteOrder.Uint32(dir[4:]))
+		name := dir[8:16]
+		dir = dir[16:]
+		if i := bytes.IndexByte(name, 0); i != -1 {
+			name = name[:i]
+		}
+		if offset < 0 || size < 0 {
+			return nil, errors.New("read WAD lump " + strconv.Quote(string(name)) + ": negative offset or size")
+		}
+
+		var data []byte
+		// marker lumps often have nonsense offsets, do not read them
+		if size > 0 {
+			data, err = readBytes(
+				io.NewSectionReader(r, int64(offset), int64(size)),
+				uint64(size),
+			)
+			if err != nil {
+				return nil, errors.New("read WAD lump " + strconv.Quote(string(name)) + ": " + err.Error())
+			}
+		}
+		b.Append(string(name), data)
+	}
+	return b, nil
+}
+
+// WriteWAD writes the blob as a Doom PWAD file. The IDs are converted to lump
+// names by making all letters upper case and cutting them to WADNameLen bytes.
+// Different IDs might thus result in the same lump name, which WAD files
+// allow. Empty items become zero-length lumps, e.g. for markers like S_START
+// and S_END.
+//
+// WriteWAD fails if an ID contains anything other than printable ASCII
+// characters or if the data is too large to be addressed in a WAD file, which
+// uses signed 32 bit offsets.
+func (b *Blob) WriteWAD(w io.Writer) error {
+	dir := bytes.NewBuffer(nil)
+	offset := int64(12)
+	for i := range b.items {
+		name, err := wadName(b.items[i].id)
+		if err != nil {
+			return errors.New("blob.Blob.WriteWAD: " + err.Error())
+		}
+		size := int64(b.items[i].end - b.items[i].start)
+		if offset+size > math.MaxInt32 {
+			return errors.New("blob.Blob.WriteWAD: data is too large for a WAD file")
+		}
+		// writing to bytes.Buffer never returns error != nil so do not check it
+		binary.Write(dir, byteOrder, int32(offset))
+		binary.Write(dir, byteOrder, int32(size))
+		dir.Write(name[:])
+		offset += size
+	}
+
+	var header [12]byte
+	copy(header[:], "PWAD")
+	byteOrder.PutUint32(header[4:], uint32(len(b.items)))
+	byteOrder.PutUint32(header[8:], uint32(offset))
+	if _, err := w.Write(header[:]); err != nil {
+		return errors.New("write WAD header: " + err.Error())
+	}
+	for i := range b.items {
+		if err := b.writeItem(w, &b.items[i]); err != nil {
+			return errors.New("write WAD lump: " + err.Error())
+		}
+	}
+	if _, err := w.Write(dir.Bytes()); err != nil {
+		return errors.New("write WAD directory: " + err.Error())
+	}
+	return nil
+}
+
+// wadName converts an ID to a lump name, see WriteWAD.
+func wadName(id string) ([WADNameLen]byte, error) {
+	var name [WADNameLen]byte
+	for i := 0; i < len(id); i++ {
+		if id[i] < ' ' || id[i] > '~' {
+			return name, errors.New("ID " + strconv.Quote(id) + " is not a valid lump name")
+		}
+	}
+	for i := 0; i < len(id) && i < WADNameLen; i++ {
+		c := id[i]
+		if 'a' <= c && c <= 'z' {
+			c -= 'a' - 'A'
+		}
+		name[i] = c
+	}
+	return name, nil
+}
@@ -0,0 +1,105 @@
+package blob_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/gonutz/blob"
+)
+
+func TestWriteWAD(t *testing.T) {
+	b := blob.New()
+	b.Append("S_START", nil)
+	b.Append("trooa1", []byte{1, 2})
+	b.Append("S_END", nil)
+	b.Append("verylongname", []byte{3})
+	var buf bytes.Buffer
+
+	err := b.WriteWAD(&buf)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkBytes(t, buf.Bytes(), []byte{
+		'P', 'W', 'A', 'D',
+		4, 0, 0, 0, // lump count
+		15, 0, 0, 0, // directory offset
+		1, 2, // TROOA1
+		3, // VERYLONG
+		12, 0, 0, 0, 0, 0, 0, 0, 'S', '_', 'S', 'T', 'A', 'R', 'T', 0,
+		12, 0, 0, 0, 2, 0, 0, 0, 'T', 'R', 'O', 'O', 'A', '1', 0, 0,
+		14, 0, 0, 0, 0, 0, 0, 0, 'S', '_', 'E', 'N', 'D', 0, 0, 0,
+		14, 0, 0, 0, 1, 0, 0, 0, 'V', 'E', 'R', 'Y', 'L', 'O', 'N', 'G',
+	})
+}
+
+func TestWriteWADRejectsNonASCIINames(t *testing.T) {
+	b := blob.New()
+	b.Append("Ä", nil)
+	var buf bytes.Buffer
+	if err := b.WriteWAD(&buf); err == nil {
+		t.Error("error expected")
+	}
+}
+
+func TestFromWAD(t *testing.T) {
+	wad := []byte{
+		'I', 'W', 'A', 'D',
+		3, 0, 0, 0,
+		15, 0, 0, 0,
+		1, 2, 3,
+		12, 0, 0, 0, 3, 0, 0, 0, 'E', '1', 'M', '1', 0, 'x', 'y', 'z',
+		99, 0, 0, 0, 0, 0, 0, 0, 'F', '_', 'S', 'T', 'A', 'R', 'T', 0,
+		0, 0, 0, 0, 0, 0, 0, 0, 'F', '_', 'E', 'N', 'D', 0, 0, 0,
+	}
+
+	b, err := blob.FromWAD(bytes.NewReader(wad))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkBlobContent(t, b,
+		"E1M1", "\x01\x02\x03",
+		"F_START", "",
+		"F_END", "",
+	)
+}
+
+func TestWADRoundTrip(t *testing.T) {
+	b := blob.New()
+	b.Append("MAP01", nil)
+	b.Append("THINGS", []byte("things"))
+	b.Append("P_START", nil)
+	b.Append("P_END", nil)
+	var buf bytes.Buffer
+	if err := b.WriteWAD(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	read, err := blob.FromWAD(bytes.NewReader(buf.Bytes()))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkBlobContent(t, read,
+		"MAP01", "",
+		"THINGS", "things",
+		"P_START", "",
+		"P_END", "",
+	)
+}
+
+func TestFromWADRejectsInvalidInput(t *testing.T) {
+	tests := map[string][]byte{
+		"short":        {'P', 'W', 'A', 'D', 0, 0},
+		"magic":        {'X', 'W', 'A', 'D', 0, 0, 0, 0, 12, 0, 0, 0},
+		"negative":     {'P', 'W', 'A', 'D', 0xFF, 0xFF, 0xFF, 0xFF, 12, 0, 0, 0},
+		"no directory": {'P', 'W', 'A', 'D', 1, 0, 0, 0, 12, 0, 0, 0},
+		"lump outside": append([]byte{'P', 'W', 'A', 'D', 1, 0, 0, 0, 12, 0, 0, 0}, 100, 0, 0, 0, 1, 0, 0, 0, 'A', 0, 0, 0, 0, 0, 0, 0),
+	}
+	for name, wad := range tests {
+		if _, err := blob.FromWAD(bytes.NewReader(wad)); err == nil {
+			t.Error(name, ": error expected")
+		}
+	}
+}